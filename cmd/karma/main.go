@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gi8lino/karma/internal/app"
 )
@@ -14,8 +16,22 @@ var (
 )
 
 func main() {
-	if err := app.Run(context.Background(), Version, os.Args[1:], os.Stdout, os.Stderr); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	// Cancel the run on SIGINT or SIGTERM; once cancelled, a second signal terminates immediately.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		cancel(&app.Interrupted{Signal: sig})
+	}()
+
+	err := app.Run(ctx, Version, os.Args[1:], os.Stdout, os.Stderr)
+	signal.Stop(signals)
+	if err != nil {
+		if !app.Reported(err) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(app.ExitCode(err))
 	}
 }
@@ -2,19 +2,87 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"syscall"
 
 	"github.com/gi8lino/karma/internal/cli"
 	"github.com/gi8lino/karma/internal/logging"
+	"github.com/gi8lino/karma/internal/plan"
 	"github.com/gi8lino/karma/internal/processor"
 
 	"github.com/containeroo/tinyflags"
 )
 
+// ErrDrift is returned in check mode when at least one kustomization is out of sync.
+var ErrDrift = errors.New("kustomizations out of sync")
+
+// ErrUnusedSkip is returned with --strict-skip when a skip pattern never matched.
+var ErrUnusedSkip = errors.New("unused skip patterns")
+
+const (
+	ExitCodeError       = 1   // Exit code for failed runs.
+	ExitCodeDrift       = 2   // Exit code for check runs that found drift.
+	ExitCodeInterrupted = 130 // Exit code for cancelled runs whose signal is unknown, as shells report SIGINT.
+)
+
+// Interrupted is the cancellation cause of a run stopped by a signal.
+type Interrupted struct {
+	Signal os.Signal
+}
+
+// Error names the signal that stopped the run.
+func (e *Interrupted) Error() string {
+	return "interrupted by " + e.Signal.String()
+}
+
+// reportedError wraps an error Run has already logged.
+type reportedError struct {
+	err error
+}
+
+// Error returns the message of the wrapped error.
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// Reported reports whether Run already logged err, as it does with JSON output; callers must not print it again.
+func Reported(err error) bool {
+	var reported *reportedError
+	return errors.As(err, &reported)
+}
+
+// ExitCode maps an error returned by Run to the process exit code.
+// Runs stopped by a signal exit with 128 plus its number, as shells report it.
+func ExitCode(err error) int {
+	var interrupted *Interrupted
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrDrift):
+		return ExitCodeDrift
+	case errors.As(err, &interrupted):
+		if sig, ok := interrupted.Signal.(syscall.Signal); ok {
+			return 128 + int(sig)
+		}
+		return ExitCodeInterrupted
+	case errors.Is(err, context.Canceled):
+		return ExitCodeInterrupted
+	default:
+		return ExitCodeError
+	}
+}
+
 // Run wires parsing, logging, and processing to execute the command.
 func Run(ctx context.Context, version string, args []string, stdOut, stdErr io.Writer) error {
-// Parse the CLI flags.
+	// Parse the CLI flags.
 	cfg, err := cli.Parse(version, args)
 	if err != nil {
 		if tinyflags.IsHelpRequested(err) || tinyflags.IsVersionRequested(err) {
@@ -24,53 +92,195 @@ func Run(ctx context.Context, version string, args []string, stdOut, stdErr io.W
 		return fmt.Errorf("CLI flags error: %w", err)
 	}
 
-// Set up the logger.
+	// Set up the logger.
 	logLevel := logging.LevelFromVerbosity(cfg.Verbosity)
 	logger := logging.New(stdOut, stdErr, logLevel)
+	logger.SetFormat(logging.Format(cfg.Output))
+	logger.SetColor(logging.ColorMode(cfg.Color))
+
+	// Log the version and configuration.
+	logger.DebugKV("version", version, "command", cfg.Command, "timeout", cfg.Timeout.String())
+
+	// Bound the whole run; cancellation stops before the next directory or write.
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	// In JSON mode the terminal error is a log event as well, so the output stays machine-readable.
+	if err := execute(ctx, cfg, logger); err != nil {
+		if logging.Format(cfg.Output) != logging.FormatJSON {
+			return err
+		}
+		logger.Error(err.Error())
+		return &reportedError{err: err}
+	}
+	return nil
+}
+
+// execute runs the parsed command.
+func execute(ctx context.Context, cfg cli.Config, logger *logging.Logger) error {
+	// Applying a plan does not walk any directory.
+	if cfg.Command == cli.CommandApply {
+		return runApply(ctx, cfg, logger)
+	}
 
-// Log the version and configuration.
-	logger.DebugKV("version", version)
 	logger.DebugKV(
 		"skip", fmt.Sprintf("%v", cfg.SkipPatterns),
+		"strict-skip", fmt.Sprintf("%v", cfg.StrictSkip),
+		"include", fmt.Sprintf("%v", cfg.IncludePatterns),
+		"skip-kind", fmt.Sprintf("%v", cfg.SkipKinds),
+		"include-kind", fmt.Sprintf("%v", cfg.IncludeKinds),
+		"manifests-only", fmt.Sprintf("%v", cfg.ManifestsOnly),
+		"non-resource-files", fmt.Sprintf("%v", cfg.NonResourceFiles),
 		"gitignore", fmt.Sprintf("%v", cfg.GitIgnore),
 		"include-dot", fmt.Sprintf("%v", cfg.IncludeDot),
+		"config", fmt.Sprintf("%v", !cfg.NoConfig),
+		"ignore-file", cfg.IgnoreFile,
 		"dir-suffix", fmt.Sprintf("%v", cfg.AddDirSuffix),
 		"dir-prefix", fmt.Sprintf("%v", cfg.AddDirPrefix),
 		"ignored-prefixes", fmt.Sprintf("%v", cfg.IgnoredPrefixes),
 		"order", fmt.Sprintf("%v", cfg.ResourceOrder),
+		"check", fmt.Sprintf("%v", cfg.Check),
+		"dry-run", fmt.Sprintf("%v", cfg.DryRun),
+		"diff", fmt.Sprintf("%v", cfg.Diff),
+		"jobs", fmt.Sprintf("%d", cfg.Jobs),
+		"keep-going", fmt.Sprintf("%v", cfg.KeepGoing),
+		"strict", fmt.Sprintf("%v", cfg.Strict),
+		"preserve-documents", fmt.Sprintf("%v", cfg.PreserveDocuments),
 	)
 
-// Create the processor options.
+	// Create the processor options.
 	opts := processor.Options{
-		Skip:            cfg.SkipPatterns,
-		UseGitIgnore:    cfg.GitIgnore,
-		IncludeDot:      cfg.IncludeDot,
-		AddDirSuffix:    cfg.AddDirSuffix,
-		AddDirPrefix:    cfg.AddDirPrefix,
-		IgnoredPrefixes: cfg.IgnoredPrefixes,
-		ResourceOrder:   cfg.ResourceOrder,
-	}
-
-// Process each base directory.
-	var totalStats processor.ResourceStats
-	for _, dir := range cfg.BaseDirs {
-		logger.Processing("base", "path", dir)
-		proc := processor.New(opts, logger)
-		stats, err := proc.Process(ctx, dir)
-		if err != nil {
-			return err
-		}
-		totalStats.Add(stats)
+		Skip:              cfg.SkipPatterns,
+		Include:           cfg.IncludePatterns,
+		SkipKinds:         cfg.SkipKinds,
+		IncludeKinds:      cfg.IncludeKinds,
+		ManifestsOnly:     cfg.ManifestsOnly,
+		NonResourceFiles:  cfg.NonResourceFiles,
+		UseGitIgnore:      cfg.GitIgnore,
+		IncludeDot:        cfg.IncludeDot,
+		UseConfig:         !cfg.NoConfig,
+		IgnoreFile:        cfg.IgnoreFile,
+		AddDirSuffix:      cfg.AddDirSuffix,
+		AddDirPrefix:      cfg.AddDirPrefix,
+		IgnoredPrefixes:   cfg.IgnoredPrefixes,
+		ResourceOrder:     cfg.ResourceOrder,
+		Check:             cfg.Check,
+		DryRun:            cfg.DryRun,
+		Diff:              cfg.Diff,
+		Plan:              cfg.Command == cli.CommandPlan,
+		Jobs:              cfg.Jobs,
+		KeepGoing:         cfg.KeepGoing,
+		Strict:            cfg.Strict,
+		PreserveDocuments: cfg.PreserveDocuments,
 	}
 
-// Print the summary.
+	// Process each base directory.
+	proc := processor.New(opts, logger)
+	totalStats, err := proc.ProcessAll(ctx, cfg.BaseDirs)
+	if err != nil {
+		return reportAbort(ctx, logger, proc, err)
+	}
+
+	// Print the summary.
 	logger.Summary(
 		totalStats.Updated,
 		totalStats.NoOp,
 		totalStats.Reordered,
 		totalStats.Added,
 		totalStats.Removed,
+		totalStats.Errors,
 	)
 
+	// Report patterns that never matched, so typos and stale entries do not go unnoticed.
+	unused := proc.UnusedSkipPatterns()
+	for _, u := range unused {
+		logger.Warn("skip pattern never matched", "pattern", u.Pattern, "source", u.Source)
+	}
+
+	// Persist the recorded changes for a later apply.
+	if opts.Plan {
+		pl := proc.Plan()
+		if err := plan.Save(cfg.PlanFile, pl); err != nil {
+			return err
+		}
+		logger.Planned(cfg.PlanFile, "entries", fmt.Sprintf("%d", len(pl.Entries)))
+	}
+
+	// List every failure recorded with --keep-going, not just the first.
+	if errs := proc.Errors(); len(errs) > 0 {
+		return fmt.Errorf("%d path(s) failed:\n%w", len(errs), errors.Join(errs...))
+	}
+
+	if cfg.StrictSkip && len(unused) > 0 {
+		return fmt.Errorf("%w: %d pattern(s) never matched", ErrUnusedSkip, len(unused))
+	}
+
+	// Signal drift so CI can fail without inspecting the output.
+	if cfg.Check && totalStats.Updated > 0 {
+		return fmt.Errorf("%w: %d kustomization(s) need an update", ErrDrift, totalStats.Updated)
+	}
+
 	return nil
 }
+
+// runApply writes the changes recorded in the plan file.
+func runApply(ctx context.Context, cfg cli.Config, logger *logging.Logger) error {
+	pl, err := plan.Load(cfg.PlanFile)
+	if err != nil {
+		return err
+	}
+
+	logger.Processing("plan", "path", cfg.PlanFile)
+	proc := processor.New(processor.Options{
+		DryRun:            cfg.DryRun,
+		Diff:              cfg.Diff,
+		Strict:            cfg.Strict,
+		PreserveDocuments: cfg.PreserveDocuments,
+	}, logger)
+	stats, err := proc.Apply(ctx, pl)
+	if err != nil && aborted(err) {
+		return reportAbort(ctx, logger, proc, err)
+	}
+
+	// Print the summary even when some entries were refused.
+	logger.Summary(
+		stats.Updated,
+		stats.NoOp,
+		stats.Reordered,
+		stats.Added,
+		stats.Removed,
+		stats.Errors,
+	)
+
+	return err
+}
+
+// aborted reports whether err stems from a cancelled or timed out run.
+func aborted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// reportAbort lists the kustomizations written before the run was aborted, so a partial run is never silent.
+// Errors that are not caused by cancellation are returned unchanged; a signal that cancelled ctx is kept in the error.
+func reportAbort(ctx context.Context, logger *logging.Logger, proc *processor.Processor, err error) error {
+	if !aborted(err) {
+		return err
+	}
+	reason := "cancelled"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "timeout"
+	}
+	written := proc.Written()
+	logger.Error("run aborted", "reason", reason, "written", fmt.Sprintf("%d", len(written)))
+	for _, path := range written {
+		logger.Warn("already updated", "kustomization", path)
+	}
+	var interrupted *Interrupted
+	if errors.As(context.Cause(ctx), &interrupted) {
+		return fmt.Errorf("run aborted after updating %d kustomization(s): %w: %w", len(written), interrupted, err)
+	}
+	return fmt.Errorf("run aborted after updating %d kustomization(s): %w", len(written), err)
+}
@@ -3,8 +3,13 @@ package app
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,6 +29,7 @@ func TestRun(t *testing.T) {
 		err := Run(context.Background(), "v1.0.0", []string{temp}, &out, &errOut)
 		require.NoError(t, err)
 		assert.Contains(t, out.String(), "[SUMMARY")
+		assert.NotContains(t, out.String(), "\x1b[", "buffers are not terminals")
 		assert.Empty(t, errOut.String())
 
 		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
@@ -32,6 +38,205 @@ func TestRun(t *testing.T) {
 		assert.Contains(t, string(data), "app.yaml")
 	})
 
+	t.Run("emits json lines", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--output", "json", temp}, &out, &errOut)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		for _, line := range lines {
+			assert.True(t, json.Valid([]byte(line)), line)
+		}
+		assert.Equal(t, `{"event":"summary","updated":1,"noop":0,"order":0,"added":1,"removed":0,"errors":0}`, lines[len(lines)-1])
+		assert.Contains(t, out.String(), `{"event":"updated","path":"`+filepath.Join(temp, "kustomization.yaml")+`"`)
+	})
+
+	t.Run("check mode reports drift", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--check", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrDrift)
+		assert.Equal(t, ExitCodeDrift, ExitCode(err))
+		assert.Contains(t, out.String(), "[DRIFT")
+
+		_, err = os.Stat(filepath.Join(temp, "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("json mode logs the terminal error", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--check", "--output", "json", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.True(t, Reported(err))
+		assert.Equal(t, ExitCodeDrift, ExitCode(err))
+		assert.Equal(t, `{"event":"error","message":"kustomizations out of sync: 1 kustomization(s) need an update"}`+"\n", errOut.String())
+	})
+
+	t.Run("text mode leaves the terminal error to the caller", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--check", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.False(t, Reported(err))
+		assert.Empty(t, errOut.String())
+	})
+
+	t.Run("dry run prints diff", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--dry-run", "--diff", temp}, &out, &errOut)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "--- /dev/null")
+		assert.Contains(t, out.String(), "+  - app.yaml")
+
+		_, err = os.Stat(filepath.Join(temp, "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("honours config file", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "values.yaml"), []byte("replicas: 1\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ".karma.yaml"), []byte("skip: [values.yaml]\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{temp}, &out, &errOut)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "app.yaml")
+		assert.NotContains(t, string(data), "values.yaml")
+	})
+
+	t.Run("strict skip fails on unused patterns", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ".karma.yaml"), []byte("skip: [valuse.yaml]\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--strict-skip", "--skip", "app.yaml,tpyo", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrUnusedSkip)
+		assert.EqualError(t, err, "unused skip patterns: 2 pattern(s) never matched")
+		assert.Equal(t, ExitCodeError, ExitCode(err))
+		assert.Contains(t, errOut.String(), "pattern=tpyo source=--skip")
+		assert.Contains(t, errOut.String(), "pattern=valuse.yaml source="+filepath.Join(temp, ".karma.yaml"))
+		assert.NotContains(t, errOut.String(), "pattern=app.yaml")
+	})
+
+	t.Run("keep going lists every failure", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		for _, dir := range []string{"a", "b", "c"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(temp, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		}
+		for _, dir := range []string{"a", "b"} {
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, ".karma.yaml"), []byte("unknown: true\n"), 0o644))
+		}
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--keep-going", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.Equal(t, ExitCodeError, ExitCode(err))
+		assert.True(t, strings.HasPrefix(err.Error(), "2 path(s) failed:\nparse "+filepath.Join(temp, "a", ".karma.yaml")+": "))
+		assert.Contains(t, err.Error(), "\nparse "+filepath.Join(temp, "b", ".karma.yaml")+": ")
+		assert.Contains(t, out.String(), "updated=2")
+		assert.Contains(t, out.String(), "errors=2")
+		_, statErr := os.Stat(filepath.Join(temp, "c", "kustomization.yaml"))
+		assert.NoError(t, statErr)
+	})
+
+	t.Run("cancelled run reports abort", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var out, errOut bytes.Buffer
+		err := Run(ctx, "v1.0.0", []string{temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.EqualError(t, err, "run aborted after updating 0 kustomization(s): context canceled")
+		assert.Equal(t, ExitCodeInterrupted, ExitCode(err))
+		assert.Contains(t, errOut.String(), "reason=cancelled written=0")
+		_, statErr := os.Stat(filepath.Join(temp, "kustomization.yaml"))
+		assert.ErrorIs(t, statErr, os.ErrNotExist)
+	})
+
+	t.Run("signal is kept in the abort", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(&Interrupted{Signal: syscall.SIGTERM})
+
+		var out, errOut bytes.Buffer
+		err := Run(ctx, "v1.0.0", []string{"--timeout", "1m", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.EqualError(t, err, "run aborted after updating 0 kustomization(s): interrupted by terminated: context canceled")
+		assert.Equal(t, 143, ExitCode(err))
+	})
+
+	t.Run("timeout aborts the run", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"--timeout", "1ns", temp}, &out, &errOut)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, ExitCodeError, ExitCode(err))
+		assert.Contains(t, errOut.String(), "reason=timeout")
+	})
+
+	t.Run("plan then apply", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		planFile := filepath.Join(t.TempDir(), "plan.json")
+
+		var out, errOut bytes.Buffer
+		err := Run(context.Background(), "v1.0.0", []string{"plan", "-o", planFile, temp}, &out, &errOut)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "[PLAN")
+		_, err = os.Stat(filepath.Join(temp, "kustomization.yaml"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		out.Reset()
+		err = Run(context.Background(), "v1.0.0", []string{"apply", planFile}, &out, &errOut)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "[UPDATED")
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "app.yaml")
+	})
+
 	t.Run("returns parse error when missing args", func(t *testing.T) {
 		t.Parallel()
 		var out, errOut bytes.Buffer
@@ -58,3 +263,33 @@ func TestRun(t *testing.T) {
 		assert.Empty(t, errOut.String())
 	})
 }
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, 0, ExitCode(nil))
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, ExitCodeDrift, ExitCode(fmt.Errorf("wrapped: %w", ErrDrift)))
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, ExitCodeInterrupted, ExitCode(fmt.Errorf("wrapped: %w", context.Canceled)))
+	})
+
+	t.Run("interrupted by a known signal", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, 130, ExitCode(fmt.Errorf("wrapped: %w", &Interrupted{Signal: syscall.SIGINT})))
+		assert.Equal(t, 143, ExitCode(fmt.Errorf("wrapped: %w", &Interrupted{Signal: syscall.SIGTERM})))
+	})
+
+	t.Run("generic error", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, ExitCodeError, ExitCode(errors.New("boom")))
+	})
+}
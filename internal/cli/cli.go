@@ -4,39 +4,170 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/containeroo/tinyflags"
+	"github.com/gi8lino/karma/internal/logging"
 	"github.com/gi8lino/karma/internal/processor"
 )
 
+// Commands selected by the first positional argument.
+const (
+	CommandSync  = "sync"  // Default: keep kustomizations in sync.
+	CommandPlan  = "plan"  // Record the changes in a plan file.
+	CommandApply = "apply" // Write the changes recorded in a plan file.
+)
+
 // Config holds parsed command-line options.
 type Config struct {
-	BaseDirs        []string
-	SkipPatterns    []string
-	Verbosity       int
-	GitIgnore       bool
-	IncludeDot      bool
-	Mute            bool
-	AddDirSuffix    bool
-	AddDirPrefix    bool
-	IgnoredPrefixes []string
-	ResourceOrder   []string
+	Command           string
+	BaseDirs          []string
+	PlanFile          string
+	SkipPatterns      []string
+	StrictSkip        bool
+	IncludePatterns   []string
+	SkipKinds         []string
+	IncludeKinds      []string
+	ManifestsOnly     bool
+	NonResourceFiles  []string
+	Verbosity         int
+	Output            string
+	Color             string
+	GitIgnore         bool
+	IncludeDot        bool
+	NoConfig          bool
+	IgnoreFile        string
+	Mute              bool
+	AddDirSuffix      bool
+	AddDirPrefix      bool
+	IgnoredPrefixes   []string
+	ResourceOrder     []string
+	Check             bool
+	DryRun            bool
+	Diff              bool
+	Jobs              int
+	KeepGoing         bool
+	Strict            bool
+	PreserveDocuments bool
+	Timeout           time.Duration
 }
 
 // Parse builds user configuration from CLI args.
 func Parse(version string, args []string) (Config, error) {
-	fs := tinyflags.NewFlagSet("karma", tinyflags.ContinueOnError)
+	cfg := Config{Command: CommandSync}
+
+	// Pick the command; a base dir named like a command must be written as ./plan.
+	if len(args) > 0 && (args[0] == CommandPlan || args[0] == CommandApply) {
+		cfg.Command = args[0]
+		args = args[1:]
+	}
+
+	name := "karma"
+	if cfg.Command != CommandSync {
+		name += " " + cfg.Command
+	}
+	fs := tinyflags.NewFlagSet(name, tinyflags.ContinueOnError)
 	fs.Version(version)
 	fs.RequirePositional(1)
-	fs.Note("*) skip accepts `*` wildcards plus `/*` to ignore a directory's contents and " +
-		"`/**` to ignore the directory while still descending into its children.")
 
-	cfg := Config{}
+	// Mode
+	switch cfg.Command {
+	case CommandSync:
+		fs.Note("*) skip accepts `*` wildcards, `**` for any number of directories, `!` to re-include " +
+			"(the last matching pattern wins), `/*` to ignore a directory's contents and " +
+			"`/**` to ignore the directory while still descending into its children.\n" +
+			"Use `karma plan -o FILE <base-dir>...` to record changes and `karma apply FILE` to write them.")
+		fs.BoolVar(&cfg.Check, "check", false, "Report out-of-sync kustomizations without writing; exit with code 2 on drift.").
+			Short("c").
+			Value()
+	case CommandPlan:
+		fs.StringVar(&cfg.PlanFile, "out", "plan.json", "Write the plan to this file.").
+			Short("o").
+			Value()
+	}
+	if cfg.Command != CommandPlan {
+		fs.BoolVar(&cfg.DryRun, "dry-run", false, "Log changes without writing any kustomization.").
+			Short("n").
+			Value()
+	}
+	fs.BoolVar(&cfg.Diff, "diff", false, "Print a unified diff for every kustomization that changes.").
+		Short("d").
+		Value()
+	if cfg.Command != CommandApply {
+		fs.BoolVar(&cfg.KeepGoing, "keep-going", false,
+			"Record failing paths and continue the walk; exit with code 1 listing every failure.").
+			Short("k").
+			Value()
+	}
+	fs.BoolVar(&cfg.Strict, "strict", false,
+		"Refuse to update kustomizations with a non-mapping document, a non-sequence resources or components block, "+
+			"or non-scalar entries, reporting file:line:column for each.").
+		Value()
+	fs.BoolVar(&cfg.PreserveDocuments, "preserve-documents", false,
+		"Update only the first document of multi-document kustomizations and keep the others verbatim instead of refusing them.").
+		Value()
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "Abort the run after this duration; files already written stay complete. 0 disables it.").
+		Placeholder("DURATION").
+		Validate(func(v time.Duration) error {
+			if v < 0 {
+				return fmt.Errorf("timeout must not be negative, got %s", v)
+			}
+			return nil
+		}).
+		Value()
+
+	// Applying a plan does not scan directories, so it has no selection flags.
+	var order *string
+	if cfg.Command != CommandApply {
+		order = registerSelectionFlags(fs, &cfg)
+	}
+	registerLoggingFlags(fs, &cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Command == CommandApply {
+		if len(fs.Args()) != 1 {
+			return Config{}, fmt.Errorf("apply expects exactly one plan file, got %d", len(fs.Args()))
+		}
+		cfg.PlanFile = fs.Args()[0]
+		return cfg, nil
+	}
+
+	cfg.BaseDirs = fs.Args()
+	cfg.ResourceOrder = processor.ParseResourceOrder(*order)
+
+	return cfg, nil
+}
 
+// registerSelectionFlags adds the flags that decide which resources are listed and how.
+func registerSelectionFlags(fs *tinyflags.FlagSet, cfg *Config) (order *string) {
 	// Selection
 	fs.StringSliceVar(&cfg.SkipPatterns, "skip", []string{}, "Skip resources (comma-separated). *").
 		Short("s").
 		Value()
+	fs.BoolVar(&cfg.StrictSkip, "strict-skip", false,
+		"Fail when a skip pattern, from the CLI or a config file, never matched.").
+		Value()
+	fs.StringSliceVar(&cfg.IncludePatterns, "include", []string{},
+		"Only manage paths matching these patterns (comma-separated); directories leading to them stay listed.").
+		Value()
+	fs.StringSliceVar(&cfg.SkipKinds, "skip-kind", []string{},
+		"Skip YAML files containing a document of these kinds (comma-separated [apiVersion/]Kind globs).").
+		Placeholder("KIND").
+		Value()
+	fs.StringSliceVar(&cfg.IncludeKinds, "include-kind", []string{},
+		"Only list YAML files containing a document of these kinds (comma-separated [apiVersion/]Kind globs).").
+		Placeholder("KIND").
+		Value()
+	fs.BoolVar(&cfg.ManifestsOnly, "manifests-only", false,
+		"Only list YAML files whose documents all have apiVersion and kind.").
+		Value()
+	fs.StringSliceVar(&cfg.NonResourceFiles, "non-resource-files", []string{},
+		"Never list YAML files with these names (comma-separated globs).").
+		Placeholder("NAME").
+		Value()
 
 	fs.BoolVar(&cfg.GitIgnore, "no-gitignore", false, "Disable .gitignore processing.").
 		Short("g").
@@ -45,9 +176,15 @@ func Parse(version string, args []string) (Config, error) {
 	fs.BoolVar(&cfg.IncludeDot, "include-dot", false, "Include hidden files and directories.").
 		Short("i").
 		Value()
+	fs.BoolVar(&cfg.NoConfig, "no-config", false, "Disable "+processor.ConfigFileName+" discovery.").
+		Value()
+	fs.StringVar(&cfg.IgnoreFile, "ignore-file", processor.DefaultIgnoreFile,
+		"Per-directory ignore file with .gitignore syntax. Empty disables it.").
+		Placeholder("NAME").
+		Value()
 
 	allowed := strings.Join(processor.DefaultResourceOrder(), ", ")
-	order := fs.String("order", allowed, fmt.Sprintf("Build the resource groups in the provided order. Valid groups: %s.", allowed)).
+	order = fs.String("order", allowed, fmt.Sprintf("Build the resource groups in the provided order. Valid groups: %s.", allowed)).
 		Validate(func(v string) error {
 			dro := processor.DefaultResourceOrder()
 			for _, entry := range strings.Split(v, ",") {
@@ -77,6 +214,23 @@ func Parse(version string, args []string) (Config, error) {
 		"Skip trailing slash for resources starting with prefixes.").
 		Value()
 
+	// Performance
+	fs.IntVar(&cfg.Jobs, "jobs", 1, "Process up to N directories concurrently; output stays in walk order.").
+		Short("j").
+		Placeholder("N").
+		Validate(func(v int) error {
+			if v < 1 {
+				return fmt.Errorf("jobs must be at least 1, got %d", v)
+			}
+			return nil
+		}).
+		Value()
+
+	return order
+}
+
+// registerLoggingFlags adds the verbosity flags shared by every command.
+func registerLoggingFlags(fs *tinyflags.FlagSet, cfg *Config) {
 	// Logging
 	fs.CounterVar(&cfg.Verbosity, "verbose", 0, "Increase verbosity. Repeat to show more details.").
 		Short("v").
@@ -93,13 +247,26 @@ func Parse(version string, args []string) (Config, error) {
 		Short("q").
 		OneOfGroup("logging").
 		Value()
-
-	if err := fs.Parse(args); err != nil {
-		return Config{}, err
-	}
-
-	cfg.BaseDirs = fs.Args()
-	cfg.ResourceOrder = processor.ParseResourceOrder(*order)
-
-	return cfg, nil
+	formats := logging.Formats()
+	fs.StringVar(&cfg.Output, "output", string(logging.FormatText),
+		fmt.Sprintf("Log format. Valid formats: %s.", strings.Join(formats, ", "))).
+		Validate(func(v string) error {
+			if !slices.Contains(formats, v) {
+				return fmt.Errorf("invalid output format: %s. allowed are: %s", v, strings.Join(formats, ", "))
+			}
+			return nil
+		}).
+		Placeholder(strings.Join(formats, "|")).
+		Value()
+	colors := logging.ColorModes()
+	fs.StringVar(&cfg.Color, "color", string(logging.ColorAuto),
+		fmt.Sprintf("Colour output. Valid modes: %s. auto honours NO_COLOR.", strings.Join(colors, ", "))).
+		Validate(func(v string) error {
+			if !slices.Contains(colors, v) {
+				return fmt.Errorf("invalid color mode: %s. allowed are: %s", v, strings.Join(colors, ", "))
+			}
+			return nil
+		}).
+		Placeholder(strings.Join(colors, "|")).
+		Value()
 }
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"testing"
+	"time"
 
+	"github.com/gi8lino/karma/internal/processor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,21 +18,52 @@ func TestParse(t *testing.T) {
 		cfg, err := Parse("1.0.0", []string{
 			"-s", ".img,dashboards",
 			"-s", "patch-*",
+			"--strict-skip",
+			"--include", "apps/**,clusters/prod",
+			"--skip-kind", "v1/Secret",
+			"--skip-kind", "kustomize.toolkit.fluxcd.io/*/Kustomization",
+			"--include-kind", "Deployment,Service",
+			"--manifests-only",
+			"--non-resource-files", "values-*.yaml",
 			"--no-gitignore",
 			"--include-dot",
+			"--no-config",
+			"--ignore-file", ".deployignore",
 			"--suffix",
 			"--prefix",
 			"--prefix-ignore", "skip",
 			"-q",
+			"--output", "json",
+			"--color", "never",
+			"--jobs", "8",
+			"--timeout", "90s",
+			"--keep-going",
+			"--strict",
+			"--preserve-documents",
 			"foo",
 		})
 		require.NoError(t, err)
 		assert.Equal(t, []string{"foo"}, cfg.BaseDirs)
 		assert.Equal(t, []string{".img", "dashboards", "patch-*"}, cfg.SkipPatterns)
+		require.True(t, cfg.StrictSkip)
+		assert.Equal(t, []string{"apps/**", "clusters/prod"}, cfg.IncludePatterns)
+		assert.Equal(t, []string{"v1/Secret", "kustomize.toolkit.fluxcd.io/*/Kustomization"}, cfg.SkipKinds)
+		assert.Equal(t, []string{"Deployment", "Service"}, cfg.IncludeKinds)
+		require.True(t, cfg.ManifestsOnly)
+		assert.Equal(t, []string{"values-*.yaml"}, cfg.NonResourceFiles)
 		require.True(t, cfg.IncludeDot)
+		require.True(t, cfg.NoConfig)
+		assert.Equal(t, ".deployignore", cfg.IgnoreFile)
 		require.True(t, cfg.AddDirSuffix)
 		require.True(t, cfg.AddDirPrefix)
 		require.True(t, cfg.Mute)
+		assert.Equal(t, "json", cfg.Output)
+		assert.Equal(t, "never", cfg.Color)
+		assert.Equal(t, 8, cfg.Jobs)
+		assert.Equal(t, 90*time.Second, cfg.Timeout)
+		require.True(t, cfg.KeepGoing)
+		require.True(t, cfg.Strict)
+		require.True(t, cfg.PreserveDocuments)
 		assert.Equal(t, -1, cfg.Verbosity, "mute should set verbosity to -1 via finalizer")
 	})
 
@@ -46,10 +79,26 @@ func TestParse(t *testing.T) {
 
 		cfg, err := Parse("1.0.0", []string{"bar"})
 		require.NoError(t, err)
+		assert.Equal(t, CommandSync, cfg.Command)
 		assert.Equal(t, []string{"bar"}, cfg.BaseDirs)
 		assert.Equal(t, []string{}, cfg.SkipPatterns)
+		require.False(t, cfg.StrictSkip)
+		assert.Equal(t, []string{}, cfg.IncludePatterns)
+		assert.Equal(t, []string{}, cfg.SkipKinds)
+		assert.Equal(t, []string{}, cfg.IncludeKinds)
+		require.False(t, cfg.ManifestsOnly)
+		assert.Equal(t, []string{}, cfg.NonResourceFiles)
 		assert.Zero(t, cfg.Verbosity)
+		assert.Equal(t, "text", cfg.Output)
+		assert.Equal(t, "auto", cfg.Color)
+		assert.Equal(t, 1, cfg.Jobs)
+		assert.Zero(t, cfg.Timeout)
+		require.False(t, cfg.KeepGoing)
+		require.False(t, cfg.Strict)
+		require.False(t, cfg.PreserveDocuments)
 		require.False(t, cfg.IncludeDot)
+		require.False(t, cfg.NoConfig)
+		assert.Equal(t, processor.DefaultIgnoreFile, cfg.IgnoreFile)
 		require.False(t, cfg.AddDirSuffix)
 		require.False(t, cfg.AddDirPrefix)
 	})
@@ -58,7 +107,7 @@ func TestParse(t *testing.T) {
 		t.Parallel()
 		cfg, err := Parse("1.0.0", []string{"--order", "remote,files,dirs", "foo"})
 		require.NoError(t, err)
-		require.Equal(t, []string{"remote", "files", "dirs"}, cfg.ResourceOrder)
+		require.Equal(t, []string{"remote", "files", "dirs", "external"}, cfg.ResourceOrder)
 	})
 
 	t.Run("missing positional", func(t *testing.T) {
@@ -84,17 +133,101 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, -1, cfg.Verbosity, "mute should set verbosity to -1 via finalizer")
 	})
 
+	t.Run("check flag", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := Parse("1.0.0", []string{"--check", "foo"})
+		require.NoError(t, err)
+		assert.True(t, cfg.Check)
+	})
+
+	t.Run("dry run with diff", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := Parse("1.0.0", []string{"--dry-run", "--diff", "foo"})
+		require.NoError(t, err)
+		assert.True(t, cfg.DryRun)
+		assert.True(t, cfg.Diff)
+		assert.False(t, cfg.Check)
+	})
+
+	t.Run("plan command", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := Parse("1.0.0", []string{"plan", "-o", "out.json", "-s", "tests", "foo", "bar"})
+		require.NoError(t, err)
+		assert.Equal(t, CommandPlan, cfg.Command)
+		assert.Equal(t, "out.json", cfg.PlanFile)
+		assert.Equal(t, []string{"foo", "bar"}, cfg.BaseDirs)
+		assert.Equal(t, []string{"tests"}, cfg.SkipPatterns)
+	})
+
+	t.Run("plan command default file", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := Parse("1.0.0", []string{"plan", "foo"})
+		require.NoError(t, err)
+		assert.Equal(t, "plan.json", cfg.PlanFile)
+	})
+
+	t.Run("apply command", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := Parse("1.0.0", []string{"apply", "--diff", "plan.json"})
+		require.NoError(t, err)
+		assert.Equal(t, CommandApply, cfg.Command)
+		assert.Equal(t, "plan.json", cfg.PlanFile)
+		assert.True(t, cfg.Diff)
+		assert.Empty(t, cfg.BaseDirs)
+	})
+
+	t.Run("apply rejects extra arguments", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse("1.0.0", []string{"apply", "a.json", "b.json"})
+		require.Error(t, err)
+		assert.EqualError(t, err, "apply expects exactly one plan file, got 2")
+	})
+
+	t.Run("apply has no selection flags", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse("1.0.0", []string{"apply", "--skip", "x", "plan.json"})
+		require.Error(t, err)
+	})
+
+	t.Run("wrong output flag", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse("1.0.0", []string{"--output", "xml", "foo"})
+		require.Error(t, err)
+		assert.EqualError(t, err, "invalid value for flag --output: invalid output format: xml. allowed are: text, json.")
+	})
+
+	t.Run("wrong color flag", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse("1.0.0", []string{"--color", "sometimes", "foo"})
+		require.Error(t, err)
+		assert.EqualError(t, err, "invalid value for flag --color: invalid color mode: sometimes. allowed are: auto, always, never.")
+	})
+
+	t.Run("wrong jobs flag", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse("1.0.0", []string{"--jobs", "0", "foo"})
+		require.Error(t, err)
+		assert.EqualError(t, err, "invalid value for flag --jobs: jobs must be at least 1, got 0.")
+	})
+
+	t.Run("wrong timeout flag", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse("1.0.0", []string{"--timeout=-1s", "foo"})
+		require.Error(t, err)
+		assert.EqualError(t, err, "invalid value for flag --timeout: timeout must not be negative, got -1s.")
+	})
+
 	t.Run("wrong order flag", func(t *testing.T) {
 		t.Parallel()
 		_, err := Parse("1.0.0", []string{"--order", "foo"})
 		require.Error(t, err)
-		assert.EqualError(t, err, "invalid value for flag --order: invalid resource order item: foo. allowed are: remote, dirs, files.")
+		assert.EqualError(t, err, "invalid value for flag --order: invalid resource order item: foo. allowed are: remote, external, dirs, files.")
 	})
 
 	t.Run("empty order flag", func(t *testing.T) {
 		t.Parallel()
 		cfg, err := Parse("1.0.0", []string{"--order", "files,dirs,,remote", "positional"})
 		require.NoError(t, err)
-		assert.Equal(t, []string{"files", "dirs", "remote"}, cfg.ResourceOrder)
+		assert.Equal(t, []string{"files", "dirs", "remote", "external"}, cfg.ResourceOrder)
 	})
 }
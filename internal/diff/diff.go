@@ -0,0 +1,180 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// op is a single line of the edit script.
+type op struct {
+	kind byte   // ' ' for unchanged, '-' for removed, '+' for added lines.
+	line string // Line content including its trailing newline, if any.
+	a, b int    // Number of old and new lines consumed before this line.
+}
+
+// Unified renders a unified diff between old and new, labelled with from and to.
+// It returns an empty string when both inputs are equal.
+func Unified(from, to string, old, new []byte) string {
+	ops := editScript(splitLines(string(old)), splitLines(string(new)))
+
+	var b strings.Builder
+	for _, h := range hunks(ops) {
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to) // nolint:errcheck
+		}
+		writeHunk(&b, h)
+	}
+	return b.String()
+}
+
+// splitLines splits s into lines, keeping the line terminators.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// editScript computes a minimal line edit script using a longest common subsequence.
+func editScript(a, b []string) []op {
+	// Trim the common prefix and suffix so the table only covers the changed region.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] holds the LCS length of midA[i:] and midB[j:].
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	ai, bi := 0, 0
+	emit := func(kind byte, line string) {
+		ops = append(ops, op{kind: kind, line: line, a: ai, b: bi})
+		if kind != '+' {
+			ai++
+		}
+		if kind != '-' {
+			bi++
+		}
+	}
+
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			emit(' ', midA[i])
+			i++
+			j++
+		case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+			emit('-', midA[i])
+			i++
+		default:
+			emit('+', midB[j])
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+	return ops
+}
+
+// hunks groups the edit script into hunks surrounded by context lines.
+func hunks(ops []op) [][]op {
+	var out [][]op
+	i := 0
+	for i < len(ops) {
+		// Find the next change.
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := max(i-contextLines, 0)
+
+		// Extend the hunk while the gap to the next change fits into the shared context.
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*contextLines {
+				end = next
+				continue
+			}
+			end = min(end+contextLines, len(ops))
+			break
+		}
+
+		out = append(out, ops[start:end])
+		i = end
+	}
+	return out
+}
+
+// writeHunk renders a single hunk including its range header.
+func writeHunk(b *strings.Builder, h []op) {
+	var oldLen, newLen int
+	for _, o := range h {
+		if o.kind != '+' {
+			oldLen++
+		}
+		if o.kind != '-' {
+			newLen++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(h[0].a, oldLen), hunkRange(h[0].b, newLen)) // nolint:errcheck
+
+	for _, o := range h {
+		b.WriteByte(o.kind)
+		b.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk range the way GNU diff does.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, length)
+	}
+}
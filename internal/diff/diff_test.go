@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+
+	t.Run("equal inputs produce no output", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, Unified("a", "b", []byte("x\n"), []byte("x\n")))
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		t.Parallel()
+		got := Unified("/dev/null", "b/kustomization.yaml", nil, []byte("---\nresources:\n  - app.yaml\n"))
+		want := "--- /dev/null\n" +
+			"+++ b/kustomization.yaml\n" +
+			"@@ -0,0 +1,3 @@\n" +
+			"+---\n" +
+			"+resources:\n" +
+			"+  - app.yaml\n"
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("changes with context", func(t *testing.T) {
+		t.Parallel()
+		old := "---\nkind: Kustomization\nresources:\n  - b.yaml\n  - a.yaml\n"
+		new := "---\napiVersion: v1\nkind: Kustomization\nresources:\n  - a.yaml\n  - b.yaml\n"
+		got := Unified("a/k.yaml", "b/k.yaml", []byte(old), []byte(new))
+		want := "--- a/k.yaml\n" +
+			"+++ b/k.yaml\n" +
+			"@@ -1,5 +1,6 @@\n" +
+			" ---\n" +
+			"+apiVersion: v1\n" +
+			" kind: Kustomization\n" +
+			" resources:\n" +
+			"-  - b.yaml\n" +
+			"   - a.yaml\n" +
+			"+  - b.yaml\n"
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("distant changes produce separate hunks", func(t *testing.T) {
+		t.Parallel()
+		old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+		new := "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+		got := Unified("a", "b", []byte(old), []byte(new))
+		assert.Contains(t, got, "@@ -1,4 +1,4 @@\n-1\n+one\n")
+		assert.Contains(t, got, "@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n")
+	})
+
+	t.Run("marks missing trailing newline", func(t *testing.T) {
+		t.Parallel()
+		got := Unified("a", "b", []byte("x"), []byte("x\n"))
+		assert.Equal(t, "--- a\n+++ b\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+x\n", got)
+	})
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/gi8lino/karma/internal/glob"
 )
 
 // Matcher decides if a path is ignored based on stacked rules.
@@ -17,139 +21,300 @@ type Matcher interface {
 	Child(dir string) (Matcher, error)
 }
 
+// FileName is the per-directory ignore file git reads.
+const FileName = ".gitignore"
+
 // Matcher implementation stores the directory-specific state required for path matching.
 type matcher struct {
-	dir      string              // Directory that owns this matcher.
+	fileName string              // Per-directory ignore file read for each child.
+	cwd      string              // Working directory used to resolve relative paths.
+	dir      string              // Absolute directory that owns this matcher.
 	parent   *matcher            // Parent matcher to inherit patterns.
-	patterns []string            // Collected patterns from this directory.
+	patterns []pattern           // Collected patterns from this directory.
+	mu       sync.Mutex          // Guards children.
 	children map[string]*matcher // Cached child matchers.
 }
 
-// Load creates a matcher rooted at dir; returns nil if useGitignore is false.
+// pattern is a single parsed .gitignore line.
+type pattern struct {
+	glob     string // Wildmatch pattern without the "!", leading "/", or trailing "/".
+	negate   bool   // Pattern started with "!" and re-includes matches.
+	dirOnly  bool   // Pattern ended with "/" and only matches directories.
+	basename bool   // Pattern has no "/" and matches the name at any depth.
+}
+
+// Load creates a matcher for dir; returns nil if useGitignore is false.
+// Inside a git repository the chain also holds the global excludes file, .git/info/exclude,
+// and every .gitignore between the repository root and dir.
 func Load(dir string, useGitignore bool) (Matcher, error) {
 	if !useGitignore {
 		return nil, nil
 	}
-	return newMatcher(dir, nil)
+	return loader{fileName: FileName, gitExcludes: true, env: osEnvironment()}.load(dir)
+}
+
+// LoadFile creates a matcher for per-directory ignore files named fileName using gitignore syntax.
+// Files between the enclosing repository root and dir apply as well; git's own excludes do not.
+func LoadFile(dir, fileName string) (Matcher, error) {
+	return loader{fileName: fileName}.load(dir)
+}
+
+// loader describes which ignore files make up a matcher chain.
+type loader struct {
+	fileName    string      // Per-directory ignore file name.
+	gitExcludes bool        // Also read .git/info/exclude and core.excludesFile.
+	env         environment // Locates the user's git configuration.
 }
 
-// Creates a matcher rooted at dir; returns nil if dir does not exist.
-func newMatcher(dir string, parent *matcher) (*matcher, error) {
-	m := &matcher{
+// load builds the matcher chain for dir.
+func (l loader) load(dir string) (*matcher, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	abs := absPath(cwd, dir)
+
+	repo, err := findRepo(abs)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return newMatcher(l.fileName, cwd, abs, nil)
+	}
+
+	// Lowest precedence first: global excludes, then info/exclude, then the per-directory files.
+	var parent *matcher
+	if l.gitExcludes {
+		for _, file := range []string{repo.excludesFile(l.env), filepath.Join(repo.commonDir, "info", "exclude")} {
+			if file == "" {
+				continue
+			}
+			patterns, err := loadPatterns(file)
+			if err != nil {
+				return nil, err
+			}
+			if len(patterns) > 0 {
+				parent = &matcher{
+					fileName: l.fileName,
+					cwd:      cwd,
+					dir:      repo.root,
+					parent:   parent,
+					patterns: patterns,
+					children: make(map[string]*matcher),
+				}
+			}
+		}
+	}
+
+	m, err := newMatcher(l.fileName, cwd, repo.root, parent)
+	if err != nil {
+		return nil, err
+	}
+	rel, _ := relTo(repo.root, abs)
+	if rel == "" {
+		return m, nil
+	}
+	dirPath := repo.root
+	for _, part := range strings.Split(rel, "/") {
+		dirPath = filepath.Join(dirPath, part)
+		if m, err = newMatcher(l.fileName, cwd, dirPath, m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Creates a matcher for dir from its ignore file; a missing file yields no patterns.
+func newMatcher(fileName, cwd, dir string, parent *matcher) (*matcher, error) {
+	patterns, err := loadPatterns(filepath.Join(dir, fileName))
+	if err != nil {
+		return nil, err
+	}
+	return &matcher{
+		fileName: fileName,
+		cwd:      cwd,
 		dir:      dir,
 		parent:   parent,
+		patterns: patterns,
 		children: make(map[string]*matcher),
-	}
+	}, nil
+}
 
-	// Load the .gitignore file if it exists.
-	path := filepath.Join(dir, ".gitignore")
+// loadPatterns parses the ignore file at path; a missing file yields no patterns.
+func loadPatterns(path string) ([]pattern, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return m, nil
+			return nil, nil
 		}
 		return nil, err
 	}
 	defer file.Close() // nolint:errcheck
 
-	// Parse the file into patterns.
-	patterns, err := parseGitignore(file)
-	if err != nil {
-		return nil, err
+	return parseGitignore(file)
+}
+
+// absPath resolves path against cwd unless it is already absolute.
+func absPath(cwd, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
 	}
-	m.patterns = patterns
-	return m, nil
+	return filepath.Join(cwd, path)
 }
 
 // Ignored reports whether the given path matches any loaded patterns.
+// Like git, a path inside an excluded directory stays excluded even if a pattern re-includes it.
 func (m *matcher) Ignored(fullPath string, isDir bool) bool {
 	if m == nil {
 		return false
 	}
+	fullPath = absPath(m.cwd, fullPath)
 
-	// Compute the relative path to the matcher.
-	rel, err := filepath.Rel(m.dir, fullPath)
-	if err != nil {
-		return m.parent.Ignored(fullPath, isDir)
-	}
-
-	// Normalize the relative path to a slash-separated string.
-	rel = filepath.ToSlash(rel)
-	if rel == "." {
-		rel = ""
+	// Check every ancestor below the outermost matcher first.
+	root := m
+	for root.parent != nil {
+		root = root.parent
 	}
-
-	for _, pattern := range m.patterns {
-		// Short-circuit when a pattern matches the relative path.
-		if matchesPattern(rel, pattern, isDir) {
+	for dir := filepath.Dir(fullPath); dir != root.dir && within(root.dir, dir); dir = filepath.Dir(dir) {
+		if m.decide(dir, true) {
 			return true
 		}
 	}
 
-	// Recurse into the parent matcher if we have one.
-	if m.parent != nil {
-		return m.parent.Ignored(fullPath, isDir)
+	return m.decide(fullPath, isDir)
+}
+
+// decide applies the patterns to fullPath alone: the deepest .gitignore is consulted first
+// and within a file the last matching pattern wins.
+func (m *matcher) decide(fullPath string, isDir bool) bool {
+	for level := m; level != nil; level = level.parent {
+		rel, ok := relTo(level.dir, fullPath)
+		if !ok {
+			continue
+		}
+		for i := len(level.patterns) - 1; i >= 0; i-- {
+			if level.patterns[i].match(rel, isDir) {
+				return !level.patterns[i].negate
+			}
+		}
 	}
 	return false
 }
 
-// Child loads or reuses the matcher for a subdirectory.
+// Child loads or reuses the matcher for a subdirectory; it is safe for concurrent use.
 func (m *matcher) Child(dir string) (Matcher, error) {
 	if m == nil {
-		return newMatcher(dir, nil)
+		return Load(dir, true)
 	}
+	dir = absPath(m.cwd, dir)
 
 	// Reuse existing child matchers.
-	if child, ok := m.children[dir]; ok {
+	m.mu.Lock()
+	child, ok := m.children[dir]
+	m.mu.Unlock()
+	if ok {
 		return child, nil
 	}
 
-	// Create a new child matcher.
-	child, err := newMatcher(dir, m)
+	// Create a new child matcher outside the lock; the first one stored wins.
+	child, err := newMatcher(m.fileName, m.cwd, dir, m)
 	if err != nil {
 		return nil, err
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.children[dir]; ok {
+		return existing, nil
+	}
 	m.children[dir] = child
 
 	return child, nil
 }
 
 // ParseGitignore reads patterns from the provided reader.
-func parseGitignore(r io.Reader) ([]string, error) {
+func parseGitignore(r io.Reader) ([]pattern, error) {
 	scanner := bufio.NewScanner(r)
-	var patterns []string
+	var patterns []pattern
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+		if p, ok := parsePattern(scanner.Text()); ok {
+			patterns = append(patterns, p)
 		}
-		patterns = append(patterns, line)
 	}
 	return patterns, scanner.Err()
 }
 
-// MatchesPattern reports whether rel matches the pattern.
-func matchesPattern(rel, pattern string, isDir bool) bool {
-	if pattern == "" {
-		return false
+// parsePattern parses one .gitignore line; ok is false for blank lines and comments.
+func parsePattern(line string) (pattern, bool) {
+	line = trimTrailingSpaces(strings.TrimSuffix(line, "\r"))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
 	}
-	if strings.HasSuffix(pattern, "/") {
-		if !isDir {
-			return false
-		}
-		pattern = strings.TrimSuffix(pattern, "/")
+
+	var p pattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
 	}
-	if pattern == "" {
-		return true
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
 	}
 
-	// Check for glob patterns.
-	if strings.ContainsAny(pattern, "*?[]") {
-		matched, err := filepath.Match(pattern, rel)
-		if err != nil {
-			return false
+	// Patterns without an inner slash match at any depth; others are anchored to the file's directory.
+	p.basename = !strings.Contains(line, "/")
+	p.glob = strings.TrimPrefix(line, "/")
+	if p.glob == "" {
+		return pattern{}, false
+	}
+	return p, true
+}
+
+// trimTrailingSpaces drops unescaped trailing spaces, keeping a space escaped with "\".
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		// Count the backslashes in front of the space; an odd number escapes it.
+		slashes := 0
+		for i := end - 2; i >= 0 && line[i] == '\\'; i-- {
+			slashes++
+		}
+		if slashes%2 == 1 {
+			break
 		}
-		return matched
+		end--
+	}
+	return line[:end]
+}
+
+// match reports whether rel, relative to the .gitignore directory, matches the pattern.
+func (p pattern) match(rel string, isDir bool) bool {
+	if rel == "" || (p.dirOnly && !isDir) {
+		return false
+	}
+	if p.basename {
+		return glob.Match(p.glob, path.Base(rel))
+	}
+	return glob.Match(p.glob, rel)
+}
+
+// relTo returns fullPath relative to dir with forward slashes; ok is false outside dir.
+func relTo(dir, fullPath string) (string, bool) {
+	rel, err := filepath.Rel(dir, fullPath)
+	if err != nil {
+		return "", false
 	}
-	return rel == pattern
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return "", true
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	_, ok := relTo(dir, path)
+	return ok
 }
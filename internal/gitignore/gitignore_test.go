@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,6 +35,30 @@ func TestLoad(t *testing.T) {
 	})
 }
 
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads the named file in every directory", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("git.yaml\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".karmaignore"), []byte("values.yaml\n"), 0o600))
+		child := filepath.Join(dir, "app")
+		require.NoError(t, os.Mkdir(child, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(child, ".karmaignore"), []byte("!values.yaml\nfixture.yaml\n"), 0o600))
+
+		m, err := LoadFile(dir, ".karmaignore")
+		require.NoError(t, err)
+		assert.True(t, m.Ignored(filepath.Join(dir, "values.yaml"), false))
+		assert.False(t, m.Ignored(filepath.Join(dir, "git.yaml"), false))
+
+		c, err := m.Child(child)
+		require.NoError(t, err)
+		assert.False(t, c.Ignored(filepath.Join(child, "values.yaml"), false))
+		assert.True(t, c.Ignored(filepath.Join(child, "fixture.yaml"), false))
+	})
+}
+
 func TestNewMatcher(t *testing.T) {
 	t.Parallel()
 
@@ -69,33 +94,68 @@ func TestMatcherChild(t *testing.T) {
 		require.NoError(t, err)
 		assert.True(t, childWithPattern.Ignored(filepath.Join(childDir, "child.txt"), false))
 	})
+
+	t.Run("shares one child across goroutines", func(t *testing.T) {
+		t.Parallel()
+		var wg sync.WaitGroup
+		children := make([]Matcher, 8)
+		for i := range children {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				children[i], _ = parent.Child(filepath.Join(dir, "shared"))
+			}()
+		}
+		wg.Wait()
+		for _, c := range children {
+			assert.Same(t, children[0], c)
+		}
+	})
 }
 
-func TestMatchesPattern(t *testing.T) {
+func TestPatternMatch(t *testing.T) {
 	t.Parallel()
 
+	matches := func(rel, line string, isDir bool) bool {
+		p, ok := parsePattern(line)
+		return ok && p.match(rel, isDir)
+	}
+
 	t.Run("matches exact path", func(t *testing.T) {
 		t.Parallel()
-		assert.True(t, matchesPattern("app.yaml", "app.yaml", false))
-		assert.False(t, matchesPattern("app.yaml", "other.yaml", false))
+		assert.True(t, matches("app.yaml", "app.yaml", false))
+		assert.False(t, matches("app.yaml", "other.yaml", false))
 	})
 
 	t.Run("handles directory suffixes", func(t *testing.T) {
 		t.Parallel()
-		assert.True(t, matchesPattern("config", "config/", true))
-		assert.False(t, matchesPattern("config/file", "config/", true))
-		assert.False(t, matchesPattern("config", "config/", false))
+		assert.True(t, matches("config", "config/", true))
+		assert.False(t, matches("config/file", "config/", true))
+		assert.False(t, matches("config", "config/", false))
 	})
 
 	t.Run("supports globbing", func(t *testing.T) {
 		t.Parallel()
-		assert.True(t, matchesPattern("docs/guide.md", "docs/*.md", false))
-		assert.False(t, matchesPattern("docs/guide.md", "src/*.md", false))
+		assert.True(t, matches("docs/guide.md", "docs/*.md", false))
+		assert.False(t, matches("docs/guide.md", "src/*.md", false))
 	})
 
 	t.Run("fails gracefully on invalid patterns", func(t *testing.T) {
 		t.Parallel()
-		assert.False(t, matchesPattern("path", "[invalid", false))
+		assert.False(t, matches("path", "[invalid", false))
+	})
+
+	t.Run("matches basename at any depth", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, matches("a/b/secret.yaml", "secret.yaml", false))
+		assert.True(t, matches("a/b/app.tmp", "*.tmp", false))
+	})
+
+	t.Run("anchors patterns with a slash", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, matches("build", "/build", true))
+		assert.False(t, matches("sub/build", "/build", true))
+		assert.False(t, matches("a/doc/frotz", "doc/frotz/", true))
 	})
 }
 
@@ -107,14 +167,193 @@ func TestParseGitignore(t *testing.T) {
 		content := "#comment\n\n# another comment\nkeep.yaml"
 		patterns, err := parseGitignore(strings.NewReader(content))
 		require.NoError(t, err)
-		assert.Equal(t, []string{"keep.yaml"}, patterns)
+		assert.Equal(t, []pattern{{glob: "keep.yaml", basename: true}}, patterns)
 	})
 
-	t.Run("trims whitespace", func(t *testing.T) {
+	t.Run("trims trailing whitespace unless escaped", func(t *testing.T) {
 		t.Parallel()
-		content := "  spaced.yaml  \n\t#ignored\n"
+		content := "spaced.yaml  \nkept\\ \r\n"
 		patterns, err := parseGitignore(strings.NewReader(content))
 		require.NoError(t, err)
-		assert.Equal(t, []string{"spaced.yaml"}, patterns)
+		assert.Equal(t, []pattern{
+			{glob: "spaced.yaml", basename: true},
+			{glob: "kept\\ ", basename: true},
+		}, patterns)
 	})
+
+	t.Run("parses negation, anchoring, and directory markers", func(t *testing.T) {
+		t.Parallel()
+		patterns, err := parseGitignore(strings.NewReader("!/foo/\n\\!bang\n\\#hash\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []pattern{
+			{glob: "foo", negate: true, dirOnly: true},
+			{glob: "\\!bang", basename: true},
+			{glob: "\\#hash", basename: true},
+		}, patterns)
+	})
+}
+
+// Examples from the gitignore(5) documentation and git's t0008-ignores.sh.
+func TestMatcherConformance(t *testing.T) {
+	t.Parallel()
+
+	type check struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}
+	cases := []struct {
+		name   string
+		files  map[string]string // .gitignore content by directory, relative to the root.
+		checks []check
+	}{
+		{
+			name:  "trailing slash matches directories at any depth",
+			files: map[string]string{"": "frotz/\n"},
+			checks: []check{
+				{"frotz", true, true},
+				{"a/frotz", true, true},
+				{"frotz", false, false},
+			},
+		},
+		{
+			name:  "inner slash anchors to the gitignore directory",
+			files: map[string]string{"": "doc/frotz/\n"},
+			checks: []check{
+				{"doc/frotz", true, true},
+				{"a/doc/frotz", true, false},
+			},
+		},
+		{
+			name:  "leading slash anchors",
+			files: map[string]string{"": "/*.c\n"},
+			checks: []check{
+				{"cat-file.c", false, true},
+				{"mozilla-sha1/sha1.c", false, false},
+			},
+		},
+		{
+			name:  "single star does not cross directories",
+			files: map[string]string{"": "foo/*\n"},
+			checks: []check{
+				{"foo/test.json", false, true},
+				{"foo/bar", true, true},
+				{"foo/bar/hello.c", false, true}, // Ignored through its excluded parent.
+				{"foo", true, false},
+			},
+		},
+		{
+			name:  "double star prefixes, suffixes, and infixes",
+			files: map[string]string{"": "**/logs\nabc/**\na/**/b\n"},
+			checks: []check{
+				{"logs", true, true},
+				{"x/y/logs", true, true},
+				{"abc/x/y.yaml", false, true},
+				{"abc", true, false},
+				{"a/b", false, true},
+				{"a/x/b", false, true},
+				{"a/x/y/b", false, true},
+			},
+		},
+		{
+			name:  "negation re-includes and the last match wins",
+			files: map[string]string{"": "*.html\n!foo.html\n"},
+			checks: []check{
+				{"bar.html", false, true},
+				{"foo.html", false, false},
+				{"sub/foo.html", false, false},
+			},
+		},
+		{
+			name:  "everything except foo/bar",
+			files: map[string]string{"": "/*\n!/foo\n/foo/*\n!/foo/bar\n"},
+			checks: []check{
+				{"top.yaml", false, true},
+				{"foo", true, false},
+				{"foo/baz", false, true},
+				{"foo/bar", true, false},
+				{"foo/bar/x.yaml", false, false},
+			},
+		},
+		{
+			name:  "excluded parent directory cannot be re-included",
+			files: map[string]string{"": "build/\n!build/keep.yaml\n"},
+			checks: []check{
+				{"build/keep.yaml", false, true},
+			},
+		},
+		{
+			name: "deeper gitignore overrides its parents",
+			files: map[string]string{
+				"":    "*.yaml\n",
+				"app": "!keep.yaml\n",
+			},
+			checks: []check{
+				{"drop.yaml", false, true},
+				{"app/drop.yaml", false, true},
+				{"app/keep.yaml", false, false},
+				{"keep.yaml", false, true},
+			},
+		},
+		{
+			name: "parent negation does not beat a child exclusion",
+			files: map[string]string{
+				"":    "!important.yaml\n",
+				"app": "*.yaml\n",
+			},
+			checks: []check{
+				{"app/important.yaml", false, true},
+			},
+		},
+		{
+			name:  "escaped special characters",
+			files: map[string]string{"": "\\!important!.txt\n\\#hash\nspace\\ \n"},
+			checks: []check{
+				{"!important!.txt", false, true},
+				{"#hash", false, true},
+				{"space ", false, true},
+				{"space", false, false},
+			},
+		},
+		{
+			name:  "character classes",
+			files: map[string]string{"": "file[0-9].yaml\n[!a]*.tmp\n"},
+			checks: []check{
+				{"file1.yaml", false, true},
+				{"filex.yaml", false, false},
+				{"b.tmp", false, true},
+				{"a.tmp", false, false},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			root := t.TempDir()
+			for dir, content := range tc.files {
+				require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(root, dir, ".gitignore"), []byte(content), 0o600))
+			}
+
+			base, err := Load(root, true)
+			require.NoError(t, err)
+			for _, c := range tc.checks {
+				// Walk the matcher chain down to the directory holding the path.
+				m := base
+				parent := filepath.Dir(filepath.Join(root, filepath.FromSlash(c.path)))
+				rel, err := filepath.Rel(root, parent)
+				require.NoError(t, err)
+				if rel != "." {
+					dir := root
+					for _, part := range strings.Split(rel, string(filepath.Separator)) {
+						dir = filepath.Join(dir, part)
+						m, err = m.Child(dir)
+						require.NoError(t, err)
+					}
+				}
+				assert.Equal(t, c.ignored, m.Ignored(filepath.Join(root, filepath.FromSlash(c.path)), c.isDir), c.path)
+			}
+		})
+	}
 }
@@ -0,0 +1,209 @@
+package gitignore
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// environment holds the user-specific locations git reads its configuration from.
+type environment struct {
+	home          string // User home directory; empty when unknown.
+	xdgConfigHome string // $XDG_CONFIG_HOME; empty falls back to ~/.config.
+}
+
+// osEnvironment reads the environment of the current process.
+func osEnvironment() environment {
+	home, _ := os.UserHomeDir()
+	return environment{home: home, xdgConfigHome: os.Getenv("XDG_CONFIG_HOME")}
+}
+
+// xdgGitDir returns the XDG git configuration directory, or "" when it cannot be determined.
+func (e environment) xdgGitDir() string {
+	switch {
+	case e.xdgConfigHome != "":
+		return filepath.Join(e.xdgConfigHome, "git")
+	case e.home != "":
+		return filepath.Join(e.home, ".config", "git")
+	default:
+		return ""
+	}
+}
+
+// repository describes the git repository enclosing a directory.
+type repository struct {
+	root      string // Working tree root holding .git.
+	gitDir    string // Repository directory; differs from root/.git for worktrees and submodules.
+	commonDir string // Directory shared by all worktrees, holding info/exclude.
+}
+
+// findRepo walks up from dir to the first directory containing .git; returns nil outside a repository.
+func findRepo(dir string) (*repository, error) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		switch {
+		case err == nil && info.IsDir():
+			return newRepository(dir, dotGit), nil
+		case err == nil:
+			// Worktrees and submodules use a file pointing at the real git dir.
+			gitDir, err := readGitFile(dotGit)
+			if err != nil {
+				return nil, err
+			}
+			return newRepository(dir, gitDir), nil
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// newRepository resolves the common dir of gitDir for a working tree rooted at root.
+func newRepository(root, gitDir string) *repository {
+	repo := &repository{root: root, gitDir: gitDir, commonDir: gitDir}
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return repo
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	repo.commonDir = filepath.Clean(common)
+	return repo
+}
+
+// readGitFile returns the git dir referenced by a "gitdir: <path>" file.
+func readGitFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", errors.New("invalid gitfile format: " + path)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// excludesFile returns the core.excludesFile path, falling back to git's XDG default.
+// Like git, the XDG config is read first, then ~/.gitconfig, then the repository config.
+func (r *repository) excludesFile(env environment) string {
+	var configs []string
+	if xdg := env.xdgGitDir(); xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "config"))
+	}
+	if env.home != "" {
+		configs = append(configs, filepath.Join(env.home, ".gitconfig"))
+	}
+	configs = append(configs, filepath.Join(r.commonDir, "config"))
+
+	value := ""
+	for _, config := range configs {
+		if v, ok := readConfigValue(config, "core", "excludesfile"); ok {
+			value = v
+		}
+	}
+
+	if value == "" {
+		if xdg := env.xdgGitDir(); xdg != "" {
+			return filepath.Join(xdg, "ignore")
+		}
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(value, "~/"); ok {
+		if env.home == "" {
+			return ""
+		}
+		return filepath.Join(env.home, rest)
+	}
+	if !filepath.IsAbs(value) {
+		return filepath.Join(r.root, value)
+	}
+	return value
+}
+
+// readConfigValue returns the last value of section.key in a git config file.
+// Section and key names are compared case-insensitively; subsections and includes are not followed.
+func readConfigValue(path, section, key string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close() // nolint:errcheck
+
+	var (
+		value   string
+		found   bool
+		current string
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				continue
+			}
+			current = strings.ToLower(strings.TrimSpace(line[1:end]))
+			line = strings.TrimSpace(line[end+1:])
+			if line == "" {
+				continue
+			}
+		}
+		if current != section {
+			continue
+		}
+
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), key) {
+			continue
+		}
+		value, found = parseConfigValue(raw), true
+	}
+	return value, found
+}
+
+// parseConfigValue strips comments, quotes, and escapes from a git config value.
+func parseConfigValue(raw string) string {
+	var b strings.Builder
+	quoted := false
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(raw[i])
+			}
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,150 @@
+package gitignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile creates path with content, including missing parent directories.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestFindRepo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("walks up to the directory holding .git", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0o755))
+		dir := filepath.Join(root, "clusters", "prod")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+
+		repo, err := findRepo(dir)
+		require.NoError(t, err)
+		require.NotNil(t, repo)
+		assert.Equal(t, root, repo.root)
+		assert.Equal(t, filepath.Join(root, ".git"), repo.commonDir)
+	})
+
+	t.Run("follows gitfiles to the common dir", func(t *testing.T) {
+		t.Parallel()
+		main := t.TempDir()
+		worktree := t.TempDir()
+		gitDir := filepath.Join(main, ".git", "worktrees", "wt")
+		writeFile(t, filepath.Join(gitDir, "commondir"), "../..\n")
+		writeFile(t, filepath.Join(worktree, ".git"), "gitdir: "+gitDir+"\n")
+
+		repo, err := findRepo(worktree)
+		require.NoError(t, err)
+		require.NotNil(t, repo)
+		assert.Equal(t, worktree, repo.root)
+		assert.Equal(t, gitDir, repo.gitDir)
+		assert.Equal(t, filepath.Join(main, ".git"), repo.commonDir)
+	})
+
+	t.Run("rejects malformed gitfiles", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, ".git"), "nonsense\n")
+		_, err := findRepo(dir)
+		require.Error(t, err)
+	})
+}
+
+func TestRepositoryExcludesFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults to the xdg ignore file", func(t *testing.T) {
+		t.Parallel()
+		home := t.TempDir()
+		repo := &repository{root: t.TempDir(), commonDir: t.TempDir()}
+		assert.Equal(t, filepath.Join(home, ".config", "git", "ignore"), repo.excludesFile(environment{home: home}))
+		assert.Equal(t, filepath.Join("/xdg", "git", "ignore"), repo.excludesFile(environment{home: home, xdgConfigHome: "/xdg"}))
+	})
+
+	t.Run("later config files win", func(t *testing.T) {
+		t.Parallel()
+		home := t.TempDir()
+		repo := &repository{root: t.TempDir(), commonDir: t.TempDir()}
+		env := environment{home: home}
+		writeFile(t, filepath.Join(home, ".config", "git", "config"), "[core]\n\texcludesFile = /from/xdg\n")
+		assert.Equal(t, "/from/xdg", repo.excludesFile(env))
+
+		writeFile(t, filepath.Join(home, ".gitconfig"), "[Core]\n  excludesfile = ~/global-ignore # comment\n")
+		assert.Equal(t, filepath.Join(home, "global-ignore"), repo.excludesFile(env))
+
+		writeFile(t, filepath.Join(repo.commonDir, "config"), "[user]\n\texcludesFile = /not/core\n[core] excludesFile = \"/from/repo\"\n")
+		assert.Equal(t, "/from/repo", repo.excludesFile(env))
+	})
+}
+
+func TestParseConfigValue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("strips quotes, comments, and escapes", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "/a b/c", parseConfigValue(` "/a b/c" ; comment`))
+		assert.Equal(t, "/a#b", parseConfigValue(`"/a#b"`))
+		assert.Equal(t, `/a\b`, parseConfigValue(`/a\\b`))
+	})
+}
+
+func TestLoadRepository(t *testing.T) {
+	t.Parallel()
+
+	t.Run("chains excludes and ancestor gitignore files", func(t *testing.T) {
+		t.Parallel()
+		home := t.TempDir()
+		root := t.TempDir()
+		writeFile(t, filepath.Join(home, ".config", "git", "ignore"), "*.global\n")
+		writeFile(t, filepath.Join(root, ".git", "info", "exclude"), "*.local\nkeep.global\n")
+		writeFile(t, filepath.Join(root, ".gitignore"), "*.root\n!keep.local\n")
+		writeFile(t, filepath.Join(root, "clusters", ".gitignore"), "/prod/secret.yaml\n")
+		base := filepath.Join(root, "clusters", "prod")
+		require.NoError(t, os.MkdirAll(base, 0o755))
+
+		m, err := loader{fileName: FileName, gitExcludes: true, env: environment{home: home}}.load(base)
+		require.NoError(t, err)
+
+		assert.True(t, m.Ignored(filepath.Join(base, "a.global"), false))
+		assert.True(t, m.Ignored(filepath.Join(base, "a.local"), false))
+		assert.True(t, m.Ignored(filepath.Join(base, "a.root"), false))
+		assert.True(t, m.Ignored(filepath.Join(base, "secret.yaml"), false))
+		assert.False(t, m.Ignored(filepath.Join(base, "app.yaml"), false))
+
+		// .gitignore beats info/exclude, which beats the global file.
+		assert.False(t, m.Ignored(filepath.Join(base, "keep.local"), false))
+		assert.True(t, m.Ignored(filepath.Join(base, "keep.global"), false))
+	})
+
+	t.Run("excluded ancestors exclude the base dir contents", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0o755))
+		writeFile(t, filepath.Join(root, ".gitignore"), "generated/\n")
+		base := filepath.Join(root, "generated", "app")
+		require.NoError(t, os.MkdirAll(base, 0o755))
+
+		m, err := loader{fileName: FileName, gitExcludes: true}.load(base)
+		require.NoError(t, err)
+		assert.True(t, m.Ignored(filepath.Join(base, "app.yaml"), false))
+	})
+
+	t.Run("outside a repository only reads the base dir", func(t *testing.T) {
+		t.Parallel()
+		base := t.TempDir()
+		writeFile(t, filepath.Join(base, ".gitignore"), "*.tmp\n")
+
+		m, err := loader{fileName: FileName, gitExcludes: true}.load(base)
+		require.NoError(t, err)
+		assert.Nil(t, m.parent)
+		assert.True(t, m.Ignored(filepath.Join(base, "x.tmp"), false))
+	})
+}
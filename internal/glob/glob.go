@@ -0,0 +1,236 @@
+package glob
+
+import "strings"
+
+// result is the outcome of matching a pattern suffix against a text suffix.
+type result int
+
+const (
+	matched         result = iota // The pattern matches the text.
+	noMatch                       // No match; a shorter "*" may still succeed.
+	abortAll                      // No match is possible at all.
+	abortToStarStar               // No match unless an enclosing "**" consumes more directories.
+)
+
+// Match reports whether name matches pattern using git's wildmatch rules for paths:
+//   - "*" and "?" never match "/", "[...]" is a character class (negated by "!" or "^")
+//     that supports ranges and [:name:] classes, and "\" escapes the next character.
+//   - "**" surrounded by slashes (or at the start or end) matches zero or more directories;
+//     anywhere else it behaves like "*".
+//
+// Malformed patterns never match.
+func Match(pattern, name string) bool {
+	return wildmatch(pattern, name) == matched
+}
+
+// wildmatch is a port of git's dowild with WM_PATHNAME.
+func wildmatch(p, text string) result {
+	pi, ti := 0, 0
+	for ; pi < len(p); pi, ti = pi+1, ti+1 {
+		pc := p[pi]
+		if ti >= len(text) && pc != '*' {
+			return abortAll
+		}
+		var tc byte
+		if ti < len(text) {
+			tc = text[ti]
+		}
+
+		switch pc {
+		case '\\':
+			// A trailing backslash never matches.
+			pi++
+			if pi >= len(p) || tc != p[pi] {
+				return noMatch
+			}
+		case '?':
+			if tc == '/' {
+				return noMatch
+			}
+		case '*':
+			matchSlash := false
+			pi++
+			if pi < len(p) && p[pi] == '*' {
+				prev := pi - 2
+				for pi < len(p) && p[pi] == '*' {
+					pi++
+				}
+				// "**" only spans directories when it forms a whole path segment.
+				if (prev < 0 || p[prev] == '/') &&
+					(pi == len(p) || p[pi] == '/' || (p[pi] == '\\' && pi+1 < len(p) && p[pi+1] == '/')) {
+					if pi < len(p) && p[pi] == '/' && wildmatch(p[pi+1:], text[ti:]) == matched {
+						return matched
+					}
+					matchSlash = true
+				}
+			}
+
+			if pi == len(p) {
+				// A trailing "**" matches everything; a trailing "*" only the rest of the segment.
+				if !matchSlash && strings.IndexByte(text[ti:], '/') >= 0 {
+					return noMatch
+				}
+				return matched
+			}
+			if !matchSlash && p[pi] == '/' {
+				// A single "*" before "/" consumes exactly the rest of the current segment.
+				slash := strings.IndexByte(text[ti:], '/')
+				if slash < 0 {
+					return noMatch
+				}
+				ti += slash
+				continue
+			}
+
+			for ti < len(text) {
+				res := wildmatch(p[pi:], text[ti:])
+				if res != noMatch {
+					if !matchSlash || res != abortToStarStar {
+						return res
+					}
+				} else if !matchSlash && text[ti] == '/' {
+					return abortToStarStar
+				}
+				ti++
+			}
+			return abortAll
+		case '[':
+			next, ok, res := matchClass(p, pi, tc)
+			if res != matched {
+				return res
+			}
+			if !ok || tc == '/' {
+				return noMatch
+			}
+			pi = next
+		default:
+			if tc != pc {
+				return noMatch
+			}
+		}
+	}
+
+	if ti < len(text) {
+		return noMatch
+	}
+	return matched
+}
+
+// matchClass matches c against the bracket expression starting at p[start].
+// It returns the index of the closing "]", whether c is in the class, and abortAll for malformed classes.
+func matchClass(p string, start int, c byte) (end int, ok bool, res result) {
+	pi := start + 1
+	if pi >= len(p) {
+		return 0, false, abortAll
+	}
+	negated := false
+	if p[pi] == '!' || p[pi] == '^' {
+		negated = true
+		pi++
+	}
+
+	found := false
+	var prev byte
+	hasPrev := false
+	for first := true; ; first = false {
+		if pi >= len(p) {
+			return 0, false, abortAll
+		}
+		pc := p[pi]
+		if pc == ']' && !first {
+			break
+		}
+
+		switch {
+		case pc == '\\':
+			pi++
+			if pi >= len(p) {
+				return 0, false, abortAll
+			}
+			pc = p[pi]
+			if c == pc {
+				found = true
+			}
+			prev, hasPrev = pc, true
+		case pc == '-' && hasPrev && pi+1 < len(p) && p[pi+1] != ']':
+			pi++
+			hi := p[pi]
+			if hi == '\\' {
+				pi++
+				if pi >= len(p) {
+					return 0, false, abortAll
+				}
+				hi = p[pi]
+			}
+			if c >= prev && c <= hi {
+				found = true
+			}
+			hasPrev = false
+		case pc == '[' && pi+1 < len(p) && p[pi+1] == ':':
+			closing := strings.IndexByte(p[pi+2:], ']')
+			if closing < 0 {
+				return 0, false, abortAll
+			}
+			name := p[pi+2 : pi+2+closing]
+			if len(name) == 0 || name[len(name)-1] != ':' {
+				// Not a [:name:] class after all; treat "[" literally.
+				if c == '[' {
+					found = true
+				}
+				prev, hasPrev = '[', true
+				break
+			}
+			in, known := inNamedClass(name[:len(name)-1], c)
+			if !known {
+				return 0, false, abortAll
+			}
+			if in {
+				found = true
+			}
+			pi += 2 + closing
+			hasPrev = false
+		default:
+			if c == pc {
+				found = true
+			}
+			prev, hasPrev = pc, true
+		}
+		pi++
+	}
+	return pi, found != negated, matched
+}
+
+// inNamedClass reports whether c belongs to the POSIX class name; known is false for unknown names.
+func inNamedClass(name string, c byte) (in, known bool) {
+	isLower := c >= 'a' && c <= 'z'
+	isUpper := c >= 'A' && c <= 'Z'
+	isDigit := c >= '0' && c <= '9'
+	switch name {
+	case "alnum":
+		return isLower || isUpper || isDigit, true
+	case "alpha":
+		return isLower || isUpper, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return c > 0x20 && c < 0x7f, true
+	case "lower":
+		return isLower, true
+	case "print":
+		return c >= 0x20 && c < 0x7f, true
+	case "punct":
+		return c > 0x20 && c < 0x7f && !isLower && !isUpper && !isDigit, true
+	case "space":
+		return c == ' ' || (c >= '\t' && c <= '\r'), true
+	case "upper":
+		return isUpper, true
+	case "xdigit":
+		return isDigit || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'), true
+	default:
+		return false, false
+	}
+}
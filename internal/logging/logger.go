@@ -1,8 +1,11 @@
 package logging
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -20,13 +23,64 @@ var tagColors = map[string]string{
 	"PROCESS":  colorCyan,
 	"SKIPPING": colorYellow,
 	"UPDATED":  colorGreen,
+	"DRIFT":    colorYellow,
+	"PLAN":     colorCyan,
 	"NO-OP":    colorBlue,
 	"TRACE":    colorPurple,
 	"SUMMARY":  colorGreen,
+	"WARNING":  colorYellow,
 	"ERROR":    colorRed,
 	"DEBUG":    colorPurple,
 }
 
+// tagEvents maps each tag to the event name used in JSON output.
+var tagEvents = map[string]string{
+	"PROCESS":  "processing",
+	"SKIPPING": "skipped",
+	"UPDATED":  "updated",
+	"DRIFT":    "drift",
+	"PLAN":     "planned",
+	"NO-OP":    "noop",
+	"TRACE":    "trace",
+	"SUMMARY":  "summary",
+	"WARNING":  "warning",
+	"ERROR":    "error",
+	"DEBUG":    "debug",
+}
+
+// jsonKeys renames text keys to their stable JSON field names.
+var jsonKeys = map[string]string{
+	"kustomization": "path",
+	"no-op":         "noop",
+}
+
+// Format selects how log lines are rendered.
+type Format string
+
+const (
+	FormatText Format = "text" // Coloured "[TAG] key=value" lines.
+	FormatJSON Format = "json" // One JSON object per line.
+)
+
+// Formats lists the supported output formats.
+func Formats() []string {
+	return []string{string(FormatText), string(FormatJSON)}
+}
+
+// ColorMode decides when ANSI colours are written.
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"   // Colour terminals unless NO_COLOR is set.
+	ColorAlways ColorMode = "always" // Always colour, even when NO_COLOR is set.
+	ColorNever  ColorMode = "never"  // Never colour.
+)
+
+// ColorModes lists the supported colour modes.
+func ColorModes() []string {
+	return []string{string(ColorAuto), string(ColorAlways), string(ColorNever)}
+}
+
 // LogLevel defines how verbose the logger should be.
 type LogLevel int
 
@@ -58,22 +112,127 @@ func LevelFromVerbosity(v int) LogLevel {
 
 // Logger formats CLI output with output streams and a minimum log level.
 type Logger struct {
-	out      io.Writer
-	err      io.Writer
+	out      stream
+	err      stream
 	minLevel LogLevel
+	format   Format
+	parent   *Logger    // Receives the buffered output on Flush; nil when writing directly.
+	buffer   *logBuffer // Output recorded until Flush; nil when writing directly.
+}
+
+// stream is an output writer and whether it receives colours.
+type stream struct {
+	w     io.Writer
+	color bool
 }
 
-// New creates a logger that renders on the provided writers.
+// paint wraps text in color when the stream is coloured.
+func (s stream) paint(color, text string) string {
+	if !s.color || color == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// New creates a logger that renders coloured output on the provided writers.
 func New(out, err io.Writer, level LogLevel) *Logger {
 	return &Logger{
-		out:      out,
-		err:      err,
+		out:      stream{w: out, color: true},
+		err:      stream{w: err, color: true},
 		minLevel: level,
+		format:   FormatText,
+	}
+}
+
+// SetColor decides per writer whether colours are written.
+// In auto mode only terminals are coloured, and only when NO_COLOR is unset or empty.
+func (l *Logger) SetColor(mode ColorMode) {
+	noColor := os.Getenv("NO_COLOR") != ""
+	l.out.color = useColor(mode, l.out.w, noColor)
+	l.err.color = useColor(mode, l.err.w, noColor)
+}
+
+// useColor resolves mode for the writer w.
+func useColor(mode ColorMode, w io.Writer, noColor bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	default:
+		return !noColor && isTerminal(w)
+	}
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// SetFormat switches the rendering of every following log line.
+func (l *Logger) SetFormat(format Format) {
+	l.format = format
+}
+
+// Buffered returns a logger with the same settings that records its output until Flush.
+// Each buffered logger must only be used by one goroutine at a time.
+func (l *Logger) Buffered() *Logger {
+	buffer := &logBuffer{}
+	return &Logger{
+		out:      stream{w: bufferWriter{buffer: buffer}, color: l.out.color},
+		err:      stream{w: bufferWriter{buffer: buffer, toErr: true}, color: l.err.color},
+		minLevel: l.minLevel,
+		format:   l.format,
+		parent:   l,
+		buffer:   buffer,
+	}
+}
+
+// Flush writes the recorded output to the parent logger in order; it does nothing for unbuffered loggers.
+func (l *Logger) Flush() {
+	if l.buffer == nil {
+		return
+	}
+	for _, rec := range l.buffer.records {
+		w := l.parent.out.w
+		if rec.toErr {
+			w = l.parent.err.w
+		}
+		w.Write(rec.data) // nolint:errcheck
 	}
+	l.buffer.records = nil
+}
+
+// logBuffer keeps the writes of a buffered logger across both streams in order.
+type logBuffer struct {
+	records []logRecord
 }
 
-// Flush exists for symmetry with buffered I/O.
-func (l *Logger) Flush() {}
+// logRecord is a single write to one of the streams.
+type logRecord struct {
+	toErr bool
+	data  []byte
+}
+
+// bufferWriter records writes for one stream of a buffered logger.
+type bufferWriter struct {
+	buffer *logBuffer
+	toErr  bool
+}
+
+// Write records a copy of p.
+func (w bufferWriter) Write(p []byte) (int, error) {
+	w.buffer.records = append(w.buffer.records, logRecord{toErr: w.toErr, data: bytes.Clone(p)})
+	return len(p), nil
+}
 
 // Processing logs the current directory when the console level allows it.
 func (l *Logger) Processing(kind string, kv ...string) {
@@ -96,6 +255,20 @@ func (l *Logger) Updated(path string, kv ...string) {
 	})
 }
 
+// Drift logs that a kustomization is out of sync but was left untouched.
+func (l *Logger) Drift(path string, kv ...string) {
+	l.log(l.out, LevelInfo, "DRIFT", func() []string {
+		return append([]string{"kustomization", path}, kv...)
+	})
+}
+
+// Planned logs that a plan file was written.
+func (l *Logger) Planned(path string, kv ...string) {
+	l.log(l.out, LevelInfo, "PLAN", func() []string {
+		return append([]string{"path", path}, kv...)
+	})
+}
+
 // NoOp logs that a kustomization was already in sync.
 func (l *Logger) NoOp(path string, kv ...string) {
 	l.log(l.out, LevelDebug, "NO-OP", func() []string {
@@ -125,7 +298,20 @@ func (l *Logger) Trace(msg string, kv ...string) {
 }
 
 // Summary prints the overall update statistics.
-func (l *Logger) Summary(updated, noOp, reordered, added, removed int) {
+func (l *Logger) Summary(updated, noOp, reordered, added, removed, errs int) {
+	if l.format == FormatJSON {
+		if LevelInfo <= l.minLevel {
+			l.writeJSON(l.out, "summary", []jsonField{
+				{"updated", updated},
+				{"noop", noOp},
+				{"order", reordered},
+				{"added", added},
+				{"removed", removed},
+				{"errors", errs},
+			})
+		}
+		return
+	}
 	l.log(l.out, LevelInfo, "SUMMARY", func() []string {
 		kv := []string{
 			"updated", fmt.Sprintf("%d", updated),
@@ -133,11 +319,19 @@ func (l *Logger) Summary(updated, noOp, reordered, added, removed int) {
 			"order", fmt.Sprintf("%d", reordered),
 			"added", fmt.Sprintf("%d", added),
 			"removed", fmt.Sprintf("%d", removed),
+			"errors", fmt.Sprintf("%d", errs),
 		}
 		return kv
 	})
 }
 
+// Warn logs a problem that does not stop the run to stderr.
+func (l *Logger) Warn(msg string, kv ...string) {
+	l.log(l.err, LevelInfo, "WARNING", func() []string {
+		return append([]string{"message", msg}, kv...)
+	})
+}
+
 // Error logs an error to stderr regardless of verbosity.
 func (l *Logger) Error(msg string, kv ...string) {
 	l.log(l.err, LevelError, "ERROR", func() []string {
@@ -145,8 +339,8 @@ func (l *Logger) Error(msg string, kv ...string) {
 	})
 }
 
-// ResourceDiff prints an old/new snapshot of the resources block.
-func (l *Logger) ResourceDiff(old, new []string) {
+// ResourceDiff prints an old/new snapshot of the block (resources or components) of the kustomization at path.
+func (l *Logger) ResourceDiff(path, block string, old, new []string) {
 	if l.minLevel < LevelVerbose {
 		return
 	}
@@ -155,11 +349,44 @@ func (l *Logger) ResourceDiff(old, new []string) {
 	if len(removed) == 0 && len(added) == 0 {
 		return
 	}
+	if l.format == FormatJSON {
+		l.writeJSON(l.out, "resource_diff", []jsonField{
+			{"path", path},
+			{"block", block},
+			{"removed", nonNil(removed)},
+			{"added", nonNil(added)},
+		})
+		return
+	}
 	for _, line := range removed {
-		fmt.Fprintf(l.out, "%s%s-  - %q%s\n", colorRed, diffIndent, line, colorReset) // nolint:errcheck
+		fmt.Fprintln(l.out.w, l.out.paint(colorRed, fmt.Sprintf("%s-  - %q", diffIndent, line))) // nolint:errcheck
 	}
 	for _, line := range added {
-		fmt.Fprintf(l.out, "%s%s+  - %q%s\n", colorGreen, diffIndent, line, colorReset) // nolint:errcheck
+		fmt.Fprintln(l.out.w, l.out.paint(colorGreen, fmt.Sprintf("%s+  - %q", diffIndent, line))) // nolint:errcheck
+	}
+}
+
+// FileDiff prints the unified diff of the kustomization at path, colouring removed and added lines.
+func (l *Logger) FileDiff(path, text string) {
+	if l.minLevel < LevelInfo || text == "" {
+		return
+	}
+	if l.format == FormatJSON {
+		l.writeJSON(l.out, "diff", []jsonField{{"path", path}, {"diff", text}})
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(line, "-"):
+			color = colorRed
+		case strings.HasPrefix(line, "+"):
+			color = colorGreen
+		}
+		fmt.Fprintln(l.out.w, l.out.paint(color, line)) // nolint:errcheck
 	}
 }
 
@@ -199,18 +426,22 @@ func diffStrings(old, new []string) (removed, added []string) {
 }
 
 // Log executes the provided builder when the configured level allows it.
-func (l *Logger) log(w io.Writer, level LogLevel, tag string, builder func() []string) {
+func (l *Logger) log(s stream, level LogLevel, tag string, builder func() []string) {
 	if level > l.minLevel || builder == nil {
 		return
 	}
 	kv := builder()
-	l.write(w, tag, kv)
+	if l.format == FormatJSON {
+		l.writeJSON(s, tagEvents[tag], jsonFields(kv))
+		return
+	}
+	l.write(s, tag, kv)
 }
 
 // Write renders a formatted log line to the configured output stream.
-func (l *Logger) write(w io.Writer, tag string, kv []string) {
+func (l *Logger) write(s stream, tag string, kv []string) {
 	var b strings.Builder
-	fmt.Fprintf(&b, "%s[%-8s]%s", tagColors[tag], tag, colorReset) // nolint:errcheck
+	b.WriteString(s.paint(tagColors[tag], fmt.Sprintf("[%-8s]", tag)))
 	for i := 0; i < len(kv); i += 2 {
 		if i+1 < len(kv) {
 			fmt.Fprintf(&b, " %s=%s", kv[i], kv[i+1]) // nolint:errcheck
@@ -218,5 +449,63 @@ func (l *Logger) write(w io.Writer, tag string, kv []string) {
 		}
 		fmt.Fprintf(&b, " %s", kv[i]) // nolint:errcheck
 	}
-	fmt.Fprintln(w, b.String()) // nolint:errcheck
+	fmt.Fprintln(s.w, b.String()) // nolint:errcheck
+}
+
+// jsonField is a single key of a JSON log line.
+type jsonField struct {
+	key   string
+	value any
+}
+
+// jsonFields converts key/value pairs into JSON fields with stable names.
+// A trailing key without a value is kept with an empty string.
+func jsonFields(kv []string) []jsonField {
+	fields := make([]jsonField, 0, len(kv)/2+1)
+	for i := 0; i < len(kv); i += 2 {
+		key := kv[i]
+		if renamed, ok := jsonKeys[key]; ok {
+			key = renamed
+		}
+		value := ""
+		if i+1 < len(kv) {
+			value = kv[i+1]
+		}
+		fields = append(fields, jsonField{key: key, value: value})
+	}
+	return fields
+}
+
+// writeJSON renders one JSON object per line, keeping the field order.
+func (l *Logger) writeJSON(s stream, event string, fields []jsonField) {
+	var b bytes.Buffer
+	b.WriteString(`{"event":`)
+	writeJSONValue(&b, event)
+	for _, f := range fields {
+		b.WriteByte(',')
+		writeJSONValue(&b, f.key)
+		b.WriteByte(':')
+		writeJSONValue(&b, f.value)
+	}
+	b.WriteString("}\n")
+	s.w.Write(b.Bytes()) // nolint:errcheck
+}
+
+// writeJSONValue appends the JSON encoding of v without escaping HTML characters.
+func writeJSONValue(b *bytes.Buffer, v any) {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		b.WriteString(`null`)
+		return
+	}
+	b.Truncate(b.Len() - 1) // Drop the newline Encode appends.
+}
+
+// nonNil returns an empty slice for nil so JSON renders [] instead of null.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
 }
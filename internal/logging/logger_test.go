@@ -2,7 +2,10 @@ package logging
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,6 +62,34 @@ func TestUpdated(t *testing.T) {
 	})
 }
 
+func TestDrift(t *testing.T) {
+	t.Parallel()
+
+	t.Run("drift", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, nil, LevelInfo)
+		logger.Drift("/tmp/kustomization.yaml", "change", "added")
+		got := stripANSI(t, out.String())
+		assert.Contains(t, got, "[DRIFT   ]")
+		assert.Contains(t, got, "change=added")
+	})
+}
+
+func TestPlanned(t *testing.T) {
+	t.Parallel()
+
+	t.Run("planned", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, nil, LevelInfo)
+		logger.Planned("plan.json", "entries", "2")
+		got := stripANSI(t, out.String())
+		assert.Contains(t, got, "[PLAN    ]")
+		assert.Contains(t, got, "path=plan.json entries=2")
+	})
+}
+
 func TestNoOp(t *testing.T) {
 	t.Parallel()
 
@@ -125,8 +156,9 @@ func TestSummary(t *testing.T) {
 		t.Parallel()
 		out := &bytes.Buffer{}
 		logger := New(out, nil, LevelInfo)
-		logger.Summary(2, 1, 0, 0, 0)
+		logger.Summary(2, 1, 0, 0, 0, 3)
 		assert.Contains(t, stripANSI(t, out.String()), "[SUMMARY ]")
+		assert.Contains(t, stripANSI(t, out.String()), "removed=0 errors=3")
 	})
 }
 
@@ -142,6 +174,26 @@ func TestError(t *testing.T) {
 	})
 }
 
+func TestWarn(t *testing.T) {
+	t.Parallel()
+
+	t.Run("warn", func(t *testing.T) {
+		t.Parallel()
+		errBuf := &bytes.Buffer{}
+		logger := New(nil, errBuf, LevelInfo)
+		logger.Warn("dangling reference", "entry", "../base")
+		assert.Contains(t, stripANSI(t, errBuf.String()), "[WARNING ] message=dangling reference entry=../base")
+	})
+
+	t.Run("muted", func(t *testing.T) {
+		t.Parallel()
+		errBuf := &bytes.Buffer{}
+		logger := New(nil, errBuf, LevelError)
+		logger.Warn("dangling reference")
+		assert.Empty(t, errBuf.String())
+	})
+}
+
 func TestResourceDiff(t *testing.T) {
 	t.Parallel()
 
@@ -149,7 +201,7 @@ func TestResourceDiff(t *testing.T) {
 		t.Parallel()
 		out := &bytes.Buffer{}
 		logger := New(out, nil, LevelVerbose)
-		logger.ResourceDiff([]string{"app", "old"}, []string{"app", "new"})
+		logger.ResourceDiff("k.yaml", "resources", []string{"app", "old"}, []string{"app", "new"})
 		stripped := stripANSI(t, out.String())
 		require.Contains(t, stripped, "+  - \"new\"")
 		require.Contains(t, stripped, "-  - \"old\"")
@@ -159,7 +211,7 @@ func TestResourceDiff(t *testing.T) {
 		t.Parallel()
 		out := &bytes.Buffer{}
 		logger := New(out, nil, LevelVerbose)
-		logger.ResourceDiff([]string{}, []string{})
+		logger.ResourceDiff("k.yaml", "resources", []string{}, []string{})
 		require.Empty(t, out.String())
 	})
 
@@ -167,7 +219,7 @@ func TestResourceDiff(t *testing.T) {
 		t.Parallel()
 		out := &bytes.Buffer{}
 		logger := New(out, nil, LevelInfo)
-		logger.ResourceDiff([]string{"app"}, []string{"app", "new"})
+		logger.ResourceDiff("k.yaml", "resources", []string{"app"}, []string{"app", "new"})
 		assert.Empty(t, stripANSI(t, out.String()))
 	})
 }
@@ -219,6 +271,27 @@ func TestDiffStrings(t *testing.T) {
 	})
 }
 
+func TestFileDiff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("prints diff lines", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, nil, LevelInfo)
+		logger.FileDiff("k.yaml", "--- a/k.yaml\n+++ b/k.yaml\n@@ -1 +1 @@\n-old\n+new\n")
+		got := stripANSI(t, out.String())
+		assert.Equal(t, "--- a/k.yaml\n+++ b/k.yaml\n@@ -1 +1 @@\n-old\n+new\n", got)
+	})
+
+	t.Run("muted", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, nil, LevelOff)
+		logger.FileDiff("k.yaml", "-old\n+new\n")
+		assert.Empty(t, out.String())
+	})
+}
+
 func TestWrite(t *testing.T) {
 	t.Parallel()
 
@@ -226,7 +299,7 @@ func TestWrite(t *testing.T) {
 		t.Parallel()
 		buf := &bytes.Buffer{}
 		logger := New(nil, nil, LevelInfo)
-		logger.write(buf, "UPDATED", []string{"kustomization", "/tmp/kustomization.yaml"})
+		logger.write(stream{w: buf, color: true}, "UPDATED", []string{"kustomization", "/tmp/kustomization.yaml"})
 		got := stripANSI(t, buf.String())
 		assert.Contains(t, got, "[UPDATED ]")
 		assert.Contains(t, got, "kustomization=/tmp/kustomization.yaml")
@@ -236,10 +309,158 @@ func TestWrite(t *testing.T) {
 		t.Parallel()
 		buf := &bytes.Buffer{}
 		logger := New(nil, nil, LevelInfo)
-		logger.write(buf, "SUMMARY", []string{"updated", "1", "no-op"})
+		logger.write(stream{w: buf, color: true}, "SUMMARY", []string{"updated", "1", "no-op"})
 		got := stripANSI(t, buf.String())
 		assert.Contains(t, got, "[SUMMARY ]")
 		assert.Contains(t, got, "updated=1")
 		assert.Contains(t, got, "no-op")
 	})
 }
+
+func TestJSONFormat(t *testing.T) {
+	t.Parallel()
+
+	newJSONLogger := func(out *bytes.Buffer, level LogLevel) *Logger {
+		logger := New(out, out, level)
+		logger.SetFormat(FormatJSON)
+		return logger
+	}
+
+	t.Run("events use stable field names", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := newJSONLogger(out, LevelDebug)
+		logger.Processing("base", "path", "apps")
+		logger.Skipped("path", "apps/test.yaml", "reason", "pattern", "pattern", "test*")
+		logger.Updated("apps/kustomization.yaml", "order", "true")
+		logger.NoOp("infra/kustomization.yaml")
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		assert.Equal(t, []string{
+			`{"event":"processing","kind":"base","path":"apps"}`,
+			`{"event":"skipped","path":"apps/test.yaml","reason":"pattern","pattern":"test*"}`,
+			`{"event":"updated","path":"apps/kustomization.yaml","order":"true"}`,
+			`{"event":"noop","path":"infra/kustomization.yaml"}`,
+		}, lines)
+	})
+
+	t.Run("summary uses numbers", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		newJSONLogger(out, LevelInfo).Summary(2, 1, 0, 3, 4, 1)
+		assert.Equal(t, `{"event":"summary","updated":2,"noop":1,"order":0,"added":3,"removed":4,"errors":1}`+"\n", out.String())
+	})
+
+	t.Run("resource diff lists entries", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		newJSONLogger(out, LevelVerbose).ResourceDiff("k.yaml", "resources", []string{"app", "old"}, []string{"app"})
+
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &event))
+		assert.Equal(t, map[string]any{
+			"event":   "resource_diff",
+			"path":    "k.yaml",
+			"block":   "resources",
+			"removed": []any{"old"},
+			"added":   []any{},
+		}, event)
+	})
+
+	t.Run("file diff keeps the text", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		newJSONLogger(out, LevelInfo).FileDiff("k.yaml", "-old\n+<new>\n")
+		assert.Equal(t, `{"event":"diff","path":"k.yaml","diff":"-old\n+<new>\n"}`+"\n", out.String())
+	})
+
+	t.Run("levels still apply", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := newJSONLogger(out, LevelInfo)
+		logger.Skipped("path", "x")
+		logger.Summary(0, 0, 0, 0, 0, 0)
+		assert.Equal(t, `{"event":"summary","updated":0,"noop":0,"order":0,"added":0,"removed":0,"errors":0}`+"\n", out.String())
+	})
+}
+
+func TestSetColor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("never strips tags and diff lines", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, out, LevelVerbose)
+		logger.SetColor(ColorNever)
+		logger.Updated("k.yaml")
+		logger.ResourceDiff("k.yaml", "resources", []string{"old"}, []string{"new"})
+		logger.Warn("careful")
+		assert.NotContains(t, out.String(), "\x1b[")
+		assert.Contains(t, out.String(), "[UPDATED ] kustomization=k.yaml")
+	})
+
+	t.Run("always colours any writer", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, out, LevelInfo)
+		logger.SetColor(ColorAlways)
+		logger.Updated("k.yaml")
+		assert.Contains(t, out.String(), colorGreen+"[UPDATED ]"+colorReset)
+	})
+
+	t.Run("auto leaves non-terminals plain", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, out, LevelInfo)
+		logger.SetColor(ColorAuto)
+		logger.FileDiff("k.yaml", "-old\n+new\n")
+		assert.Equal(t, "-old\n+new\n", out.String())
+	})
+
+	t.Run("resolves modes", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, useColor(ColorAlways, &bytes.Buffer{}, true))
+		assert.False(t, useColor(ColorNever, os.Stdout, false))
+		assert.False(t, useColor(ColorAuto, &bytes.Buffer{}, false))
+		assert.False(t, useColor(ColorAuto, os.Stdout, true))
+	})
+}
+
+func TestBuffered(t *testing.T) {
+	t.Parallel()
+
+	t.Run("flushes both streams in order", func(t *testing.T) {
+		t.Parallel()
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		logger := New(out, errOut, LevelInfo)
+		logger.SetColor(ColorNever)
+
+		buffered := logger.Buffered()
+		nested := buffered.Buffered()
+		buffered.Updated("a.yaml")
+		nested.Warn("dangling")
+		nested.Updated("b.yaml")
+		assert.Empty(t, out.String())
+
+		nested.Flush()
+		buffered.Flush()
+		assert.Equal(t, "[UPDATED ] kustomization=a.yaml\n[UPDATED ] kustomization=b.yaml\n", out.String())
+		assert.Equal(t, "[WARNING ] message=dangling\n", errOut.String())
+
+		buffered.Flush()
+		assert.Equal(t, "[UPDATED ] kustomization=a.yaml\n[UPDATED ] kustomization=b.yaml\n", out.String())
+	})
+
+	t.Run("keeps format and level", func(t *testing.T) {
+		t.Parallel()
+		out := &bytes.Buffer{}
+		logger := New(out, out, LevelInfo)
+		logger.SetFormat(FormatJSON)
+
+		buffered := logger.Buffered()
+		buffered.Skipped("path", "x")
+		buffered.NoOp("k.yaml")
+		buffered.Updated("k.yaml")
+		buffered.Flush()
+		assert.Equal(t, `{"event":"updated","path":"k.yaml"}`+"\n", out.String())
+	})
+}
@@ -0,0 +1,70 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Version is the current plan file format version.
+const Version = 1
+
+// Plan is a serializable set of kustomization changes.
+type Plan struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+
+	PreserveDocuments bool `json:"preserveDocuments,omitempty"` // Entries were planned with --preserve-documents.
+}
+
+// Entry records the change planned for a single kustomization.
+type Entry struct {
+	Path   string   `json:"path"`   // Kustomization file to write.
+	Exists bool     `json:"exists"` // True when the file existed at planning time.
+	Hash   string   `json:"hash"`   // Hash of the original file; empty when it did not exist.
+	Old    []string `json:"old"`    // Resources before the change.
+	New    []string `json:"new"`    // Resources after the change.
+
+	OldComponents []string `json:"oldComponents,omitempty"` // Components before the change.
+	NewComponents []string `json:"newComponents,omitempty"` // Components after the change.
+}
+
+// Hash returns the content hash stored in plan entries.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Save writes the plan as indented JSON to path.
+func Save(path string, p Plan) error {
+	p.Version = Version
+	if p.Entries == nil {
+		p.Entries = []Entry{}
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write plan: %w", err)
+	}
+	return nil
+}
+
+// Load reads a plan from path and validates its version.
+func Load(path string) (Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Plan{}, fmt.Errorf("read plan: %w", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Plan{}, fmt.Errorf("decode plan %s: %w", path, err)
+	}
+	if p.Version != Version {
+		return Plan{}, fmt.Errorf("unsupported plan version %d in %s", p.Version, path)
+	}
+	return p, nil
+}
@@ -0,0 +1,72 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stable sha256", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Hash(nil))
+		assert.NotEqual(t, Hash([]byte("a")), Hash([]byte("b")))
+	})
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "plan.json")
+		in := Plan{Entries: []Entry{{
+			Path:   "apps/kustomization.yaml",
+			Exists: true,
+			Hash:   Hash([]byte("x")),
+			Old:    []string{"a.yaml"},
+			New:    []string{"a.yaml", "b.yaml"},
+		}}, PreserveDocuments: true}
+		require.NoError(t, Save(path, in))
+
+		out, err := Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, Version, out.Version)
+		assert.Equal(t, in.Entries, out.Entries)
+		assert.True(t, out.PreserveDocuments)
+	})
+
+	t.Run("empty plan writes an empty list", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "plan.json")
+		require.NoError(t, Save(path, Plan{}))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"entries": []`)
+	})
+
+	t.Run("rejects unknown version", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "plan.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"version": 99, "entries": []}`), 0o644))
+
+		_, err := Load(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported plan version 99")
+	})
+
+	t.Run("rejects invalid json", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "plan.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{`), 0o644))
+
+		_, err := Load(path)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gi8lino/karma/internal/plan"
+)
+
+// ErrStalePlan is returned when plan entries no longer match the files on disk.
+var ErrStalePlan = errors.New("stale plan")
+
+// Apply writes the changes recorded in a plan, refusing entries whose file changed since planning.
+// Files are read the way the plan run read them; on error the stats cover the entries already written.
+func (p *Processor) Apply(ctx context.Context, pl plan.Plan) (ResourceStats, error) {
+	if pl.PreserveDocuments && !p.opts.PreserveDocuments {
+		derived := *p
+		derived.opts.PreserveDocuments = true
+		p = &derived
+	}
+
+	var stats ResourceStats
+	var stale []string
+	for _, entry := range pl.Entries {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		doc, err := p.loadPlannedKustomization(entry)
+		if err != nil {
+			if errors.Is(err, ErrStalePlan) {
+				p.logger.Error("refusing stale plan entry", "kustomization", entry.Path)
+				stale = append(stale, entry.Path)
+				continue
+			}
+			return stats, err
+		}
+
+		upd, err := p.rewriteKustomization(ctx, entry.Path, entry.Exists, doc, entry.New, entry.NewComponents)
+		if err != nil {
+			return stats, err
+		}
+		if !upd.changed {
+			p.logger.NoOp(entry.Path)
+			stats.NoOp++
+			continue
+		}
+		fileStats := p.logUpdate(entry.Path, upd)
+		fileStats.Updated = 1
+		stats.Add(fileStats)
+	}
+
+	if len(stale) > 0 {
+		return stats, fmt.Errorf("%w: changed since planning: %s", ErrStalePlan, strings.Join(stale, ", "))
+	}
+	return stats, nil
+}
+
+// loadPlannedKustomization loads the entry's file after verifying it still matches the plan.
+// The hash is compared before parsing, so a file broken since planning is refused as stale.
+func (p *Processor) loadPlannedKustomization(entry plan.Entry) (*kustomizationDoc, error) {
+	if !entry.Exists {
+		// The file must still be missing, otherwise someone created it meanwhile.
+		_, err := os.Stat(entry.Path)
+		switch {
+		case err == nil:
+			return nil, ErrStalePlan
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+		return p.loadKustomization(entry.Path, false)
+	}
+
+	data, err := os.ReadFile(entry.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrStalePlan
+		}
+		return nil, err
+	}
+	if plan.Hash(data) != entry.Hash {
+		return nil, ErrStalePlan
+	}
+	return p.parseKustomization(entry.Path, data, true)
+}
@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/gi8lino/karma/internal/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorPlan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records changes without writing", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("resources:\n  - old.yaml\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+		proc := New(Options{Plan: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Updated)
+
+		pl := proc.Plan()
+		require.Len(t, pl.Entries, 1)
+		entry := pl.Entries[0]
+		assert.Equal(t, path, entry.Path)
+		assert.True(t, entry.Exists)
+		assert.Equal(t, plan.Hash([]byte("resources:\n  - old.yaml\n")), entry.Hash)
+		assert.Equal(t, []string{"old.yaml"}, entry.Old)
+		assert.Equal(t, []string{"app.yaml"}, entry.New)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "resources:\n  - old.yaml\n", string(data))
+	})
+}
+
+func TestProcessorApply(t *testing.T) {
+	t.Parallel()
+
+	t.Run("applies planned entries", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
+		planner := New(Options{Plan: true}, logger)
+		_, err := planner.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		stats, err := New(Options{}, logger).Apply(context.Background(), planner.Plan())
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Updated)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- app.yaml")
+	})
+
+	t.Run("refuses entries whose file changed", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("resources: []\n"), 0o644))
+		pl := plan.Plan{Version: plan.Version, Entries: []plan.Entry{{
+			Path:   path,
+			Exists: true,
+			Hash:   plan.Hash([]byte("something else")),
+			New:    []string{"app.yaml"},
+		}}}
+
+		_, err := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo)).Apply(context.Background(), pl)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrStalePlan)
+		assert.Contains(t, err.Error(), path)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "resources: []\n", string(data))
+	})
+
+	t.Run("refuses entries whose file no longer parses as stale", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("resources: [\n"), 0o644))
+		pl := plan.Plan{Version: plan.Version, Entries: []plan.Entry{{
+			Path:   path,
+			Exists: true,
+			Hash:   plan.Hash([]byte("resources: []\n")),
+			New:    []string{"app.yaml"},
+		}}}
+
+		_, err := New(Options{Strict: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo)).Apply(context.Background(), pl)
+		require.ErrorIs(t, err, ErrStalePlan)
+	})
+
+	t.Run("returns the stats of entries written before an error", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		freshPath := filepath.Join(temp, "fresh", "kustomization.yaml")
+		brokenPath := filepath.Join(temp, "broken", "kustomization.yaml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(freshPath), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Dir(brokenPath), 0o755))
+		require.NoError(t, os.WriteFile(brokenPath, []byte("resources: [\n"), 0o644))
+		pl := plan.Plan{Version: plan.Version, Entries: []plan.Entry{
+			{Path: freshPath, New: []string{"a.yaml"}},
+			{Path: brokenPath, Exists: true, Hash: plan.Hash([]byte("resources: [\n")), New: []string{"b.yaml"}},
+		}}
+
+		stats, err := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo)).Apply(context.Background(), pl)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrStalePlan)
+		assert.Equal(t, 1, stats.Updated)
+	})
+
+	t.Run("refuses entries for files created after planning", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("resources: []\n"), 0o644))
+		pl := plan.Plan{Version: plan.Version, Entries: []plan.Entry{{Path: path, New: []string{"app.yaml"}}}}
+
+		_, err := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo)).Apply(context.Background(), pl)
+		require.ErrorIs(t, err, ErrStalePlan)
+	})
+
+	t.Run("applies remaining entries next to stale ones", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		stalePath := filepath.Join(temp, "stale", "kustomization.yaml")
+		freshPath := filepath.Join(temp, "fresh", "kustomization.yaml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(stalePath), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Dir(freshPath), 0o755))
+		require.NoError(t, os.WriteFile(stalePath, []byte("resources: []\n"), 0o644))
+		pl := plan.Plan{Version: plan.Version, Entries: []plan.Entry{
+			{Path: stalePath, New: []string{"a.yaml"}},
+			{Path: freshPath, New: []string{"b.yaml"}},
+		}}
+
+		stats, err := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo)).Apply(context.Background(), pl)
+		require.ErrorIs(t, err, ErrStalePlan)
+		assert.Equal(t, 1, stats.Updated)
+
+		data, err := os.ReadFile(freshPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- b.yaml")
+	})
+}
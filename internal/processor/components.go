@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// listing holds the entries scanned from a directory, grouped by the block they belong to.
+type listing struct {
+	dirs       []string // Subdirectories listed under resources.
+	files      []string // YAML files listed under resources.
+	components []string // Subdirectories that are Kustomize Components.
+
+	keepExisting bool     // Only add entries; the directory merely leads to included paths.
+	matcher      matchers // Ignore matchers of the directory, to look below its subdirectories.
+}
+
+// splitComponents moves directories whose kustomization is a Component out of the resource directories.
+func (p *Processor) splitComponents(dir string, dirEntries, fileEntries []string) (listing, error) {
+	entries := listing{files: fileEntries}
+	for _, name := range dirEntries {
+		component, err := p.isComponentDir(filepath.Join(dir, name))
+		if err != nil {
+			return listing{}, err
+		}
+		if component {
+			entries.components = append(entries.components, name)
+			continue
+		}
+		entries.dirs = append(entries.dirs, name)
+	}
+	return entries, nil
+}
+
+// isComponentDir reports whether the kustomization in dir declares kind Component.
+// A kustomization that does not parse counts as a resource; the walk reports it once it reaches dir.
+func (p *Processor) isComponentDir(dir string) (bool, error) {
+	path, exists, err := p.pickKustomizationPath(dir)
+	if err != nil || !exists {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	// Only the kind is needed, so decode into a minimal header.
+	var header struct {
+		Kind string `yaml:"kind"`
+	}
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&header); err != nil && !errors.Is(err, io.EOF) {
+		p.logger.Debug("cannot read kind", "path", path, "error", err.Error())
+		return false, nil
+	}
+	return header.Kind == kindComponent, nil
+}
+
+// mergeComponents produces the canonical ordering for components.
+// Remote and out-of-tree entries are kept; local entries are rebuilt from the Component directories.
+func (p *Processor) mergeComponents(dir string, existing, componentEntries, keptEntries []string) []string {
+	return p.mergeResources(dir, existing, componentEntries, nil, keptEntries)
+}
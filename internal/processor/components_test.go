@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorSplitComponents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("routes component directories", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "app"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "monitoring"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "empty"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app", "kustomization.yaml"), []byte("kind: Kustomization\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "monitoring", "kustomization.yml"), []byte("apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\n"), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		entries, err := proc.splitComponents(temp, []string{"app", "empty", "monitoring"}, []string{"a.yaml"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app", "empty"}, entries.dirs)
+		assert.Equal(t, []string{"monitoring"}, entries.components)
+		assert.Equal(t, []string{"a.yaml"}, entries.files)
+	})
+
+	t.Run("lists malformed child kustomization as a resource", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "broken"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "broken", "kustomization.yaml"), []byte("kind: [\n"), 0o644))
+		out := &bytes.Buffer{}
+		proc := New(Options{}, logging.New(out, io.Discard, logging.LevelDebug))
+
+		entries, err := proc.splitComponents(temp, []string{"broken"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"broken"}, entries.dirs)
+		assert.Empty(t, entries.components)
+		assert.Contains(t, out.String(), "cannot read kind")
+	})
+}
+
+func TestProcessorMergeComponents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps remote and out-of-tree entries", func(t *testing.T) {
+		t.Parallel()
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		got := proc.mergeComponents("", []string{"stale", "../../components/tls", "https://example.com/c"}, []string{"b", "a"}, nil)
+		assert.Equal(t, []string{"https://example.com/c", "../../components/tls", "a", "b"}, got)
+	})
+
+	t.Run("empty without components", func(t *testing.T) {
+		t.Parallel()
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		assert.Empty(t, proc.mergeComponents("", nil, nil, nil))
+	})
+}
+
+func TestProcessorComponents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lists components separately and writes component header", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		comp := filepath.Join(temp, "monitoring")
+		require.NoError(t, os.MkdirAll(comp, 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "app"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(comp, "kustomization.yaml"), []byte("kind: Component\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(comp, "patch.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "---\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - app\ncomponents:\n  - monitoring\n", string(data))
+
+		data, err = os.ReadFile(filepath.Join(comp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "---\napiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nresources:\n  - patch.yaml\n", string(data))
+	})
+
+	t.Run("leaves files without components untouched", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		content := "---\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - app.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.NoOp)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	})
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the per-directory configuration file karma discovers while walking.
+const ConfigFileName = ".karma.yaml"
+
+// dirConfig mirrors the options a .karma.yaml file may set for its subtree.
+// Skip patterns extend the inherited ones; every other key overrides it.
+type dirConfig struct {
+	Skip         []string `yaml:"skip"`
+	Order        []string `yaml:"order"`
+	Suffix       *bool    `yaml:"suffix"`
+	Prefix       *bool    `yaml:"prefix"`
+	PrefixIgnore []string `yaml:"prefixIgnore"`
+	GitIgnore    *bool    `yaml:"gitignore"`
+	IncludeDot   *bool    `yaml:"includeDot"`
+}
+
+// loadDirConfig reads the configuration file in dir; returns nil when none exists.
+func loadDirConfig(dir string) (*dirConfig, error) {
+	path := filepath.Join(dir, ConfigFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Reject unknown keys so typos do not silently change behaviour.
+	cfg := &dirConfig{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, &PathError{Op: OpParse, Path: path, Err: err}
+	}
+
+	// Validate the order groups the same way the CLI does.
+	for _, group := range cfg.Order {
+		if !slices.Contains(defaultResourceOrder, strings.ToLower(strings.TrimSpace(group))) {
+			return nil, &PathError{Op: OpParse, Path: path, Err: fmt.Errorf("invalid resource order item: %s", group)}
+		}
+	}
+
+	return cfg, nil
+}
+
+// apply layers the configuration on top of opts for the subtree.
+func (c *dirConfig) apply(opts Options) Options {
+	if len(c.Skip) > 0 {
+		opts.Skip = append(slices.Clone(opts.Skip), c.Skip...)
+	}
+	if len(c.Order) > 0 {
+		opts.ResourceOrder = normalizeResourceOrder(c.Order)
+	}
+	if c.Suffix != nil {
+		opts.AddDirSuffix = *c.Suffix
+	}
+	if c.Prefix != nil {
+		opts.AddDirPrefix = *c.Prefix
+	}
+	if c.PrefixIgnore != nil {
+		opts.IgnoredPrefixes = slices.Clone(c.PrefixIgnore)
+	}
+	if c.GitIgnore != nil {
+		opts.UseGitIgnore = *c.GitIgnore
+	}
+	if c.IncludeDot != nil {
+		opts.IncludeDot = *c.IncludeDot
+	}
+	return opts
+}
+
+// withDirConfig returns a processor for the subtree rooted at dir, honouring its config file.
+func (p *Processor) withDirConfig(dir, base string) (*Processor, error) {
+	if !p.opts.UseConfig {
+		return p, nil
+	}
+	cfg, err := loadDirConfig(dir)
+	if err != nil || cfg == nil {
+		return p, err
+	}
+	p.logger.Debug("config", "path", filepath.Join(dir, ConfigFileName))
+
+	// Scope new skip patterns to the directory that declared them.
+	scope := p.relPath(base, dir)
+	if dir == base {
+		scope = ""
+	}
+	scoped := parseSkipRules(cfg.Skip)
+	for i := range scoped {
+		scoped[i].scope = scope
+		scoped[i].source = filepath.Join(dir, ConfigFileName)
+	}
+	scoped = p.state.registerSkipRules(scoped)
+	p.declared.rules = append(p.declared.rules, scoped...)
+	rules := append(slices.Clone(p.skipRules), scoped...)
+
+	derived := *p
+	derived.opts = cfg.apply(p.opts)
+	derived.skipRules = rules
+	return &derived, nil
+}
@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDirConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns nil when missing", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := loadDirConfig(t.TempDir())
+		require.NoError(t, err)
+		assert.Nil(t, cfg)
+	})
+
+	t.Run("parses all keys", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		content := "skip:\n  - tests\norder: [files, dirs]\nsuffix: true\nprefix: false\nprefixIgnore: [\"../\"]\ngitignore: true\nincludeDot: true\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ConfigFileName), []byte(content), 0o644))
+
+		cfg, err := loadDirConfig(temp)
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, []string{"tests"}, cfg.Skip)
+		assert.Equal(t, []string{"files", "dirs"}, cfg.Order)
+		require.NotNil(t, cfg.Suffix)
+		assert.True(t, *cfg.Suffix)
+		require.NotNil(t, cfg.Prefix)
+		assert.False(t, *cfg.Prefix)
+		assert.Equal(t, []string{"../"}, cfg.PrefixIgnore)
+		assert.True(t, *cfg.GitIgnore)
+		assert.True(t, *cfg.IncludeDot)
+	})
+
+	t.Run("accepts empty file", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ConfigFileName), nil, 0o644))
+
+		cfg, err := loadDirConfig(temp)
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+	})
+
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ConfigFileName), []byte("skipp: [x]\n"), 0o644))
+
+		_, err := loadDirConfig(temp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ConfigFileName)
+	})
+
+	t.Run("rejects invalid order", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ConfigFileName), []byte("order: [remote, nope]\n"), 0o644))
+
+		_, err := loadDirConfig(temp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid resource order item: nope")
+	})
+}
+
+func TestDirConfigApply(t *testing.T) {
+	t.Parallel()
+
+	t.Run("extends skip and overrides the rest", func(t *testing.T) {
+		t.Parallel()
+		enabled := true
+		cfg := &dirConfig{
+			Skip:   []string{"child"},
+			Order:  []string{"files"},
+			Suffix: &enabled,
+		}
+		parent := Options{Skip: []string{"parent"}, AddDirPrefix: true}
+
+		got := cfg.apply(parent)
+		assert.Equal(t, []string{"parent", "child"}, got.Skip)
+		assert.Equal(t, []string{"files", "remote", "external", "dirs"}, got.ResourceOrder)
+		assert.True(t, got.AddDirSuffix)
+		assert.True(t, got.AddDirPrefix)
+		assert.Equal(t, []string{"parent"}, parent.Skip, "parent options must not be modified")
+	})
+}
+
+func TestProcessorDirConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nested config applies to its subtree only", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		apps := filepath.Join(temp, "apps")
+		require.NoError(t, os.MkdirAll(filepath.Join(apps, "web"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ConfigFileName), []byte("suffix: true\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(apps, ConfigFileName), []byte("skip: [debug.yaml]\norder: [files, dirs]\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(apps, "debug.yaml"), []byte("kind: Pod\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(apps, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "debug.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		proc := New(Options{UseConfig: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		root, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(root), "- apps/\n")
+		assert.Contains(t, string(root), "- debug.yaml\n", "scoped skip must not apply to the parent")
+		assert.NotContains(t, string(root), ConfigFileName)
+
+		data, err := os.ReadFile(filepath.Join(apps, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "  - app.yaml\n  - web/\n")
+		assert.NotContains(t, string(data), "debug.yaml")
+	})
+
+	t.Run("ignored when disabled", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, ConfigFileName), []byte("skip: [app.yaml]\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "app.yaml")
+	})
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrMultiDocument is returned for kustomizations holding more than one YAML document,
+// since rewriting them would drop everything after the first.
+var ErrMultiDocument = errors.New("multi-document kustomization")
+
+// splitDocuments splits raw at the start of the line that ends the first YAML document with content.
+// Empty documents before it, such as a lone "---", stay part of first.
+// rest starts with that "---" or "..." marker and is empty when raw holds a single document.
+func splitDocuments(raw []byte) (first, rest []byte) {
+	offset := 0
+	for {
+		head, tail := splitDocument(raw[offset:])
+		offset += len(head)
+		if len(tail) == 0 || hasDocuments(head) {
+			return raw[:offset], raw[offset:]
+		}
+	}
+}
+
+// splitDocument splits raw at the start of the line that ends its first YAML document.
+func splitDocument(raw []byte) (first, rest []byte) {
+	started := false
+	for offset := 0; offset < len(raw); {
+		next := len(raw)
+		if end := bytes.IndexByte(raw[offset:], '\n'); end >= 0 {
+			next = offset + end + 1
+		}
+		line := strings.TrimRight(string(raw[offset:next]), "\r\n")
+
+		switch {
+		case started && (isDocumentMarker(line, "---") || isDocumentMarker(line, "...")):
+			return raw[:offset], raw[offset:]
+		case isDocumentMarker(line, "---"):
+			// An explicit start of the first document.
+			started = true
+		case !isBlankOrComment(line) && !strings.HasPrefix(line, "%"):
+			started = true
+		}
+		offset = next
+	}
+	return raw, nil
+}
+
+// isDocumentMarker reports whether line is the document marker at the start of a line.
+func isDocumentMarker(line, marker string) bool {
+	rest, ok := strings.CutPrefix(line, marker)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// hasDocuments reports whether the YAML stream in raw holds a document with content.
+// Streams that cannot be parsed count as content, so they are never discarded silently.
+func hasDocuments(raw []byte) bool {
+	// The decoder rejects a stream starting with the end marker of the previous document.
+	for {
+		line, rest, _ := bytes.Cut(raw, []byte("\n"))
+		if !isDocumentMarker(strings.TrimRight(string(line), "\r"), "...") {
+			break
+		}
+		raw = rest
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			return !errors.Is(err, io.EOF)
+		}
+		if len(doc.Content) > 0 && !isNull(doc.Content[0]) {
+			return true
+		}
+	}
+}
+
+// decodeDocument decodes the first document with content in data into root, or the first document when all are empty.
+// Decoding the whole stream keeps the positions of the nodes relative to data.
+func decodeDocument(data []byte, root *yaml.Node) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for decoded := false; ; decoded = true {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		content := len(doc.Content) > 0 && !isNull(doc.Content[0])
+		if !decoded || content {
+			*root = doc
+		}
+		if content {
+			return nil
+		}
+	}
+}
+
+// multiDocumentError explains why the kustomization whose first document is first cannot be rewritten.
+func multiDocumentError(first []byte) error {
+	line := bytes.Count(first, []byte("\n")) + 1
+	return fmt.Errorf("%w: another document starts at line %d and would be lost on rewrite", ErrMultiDocument, line)
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDocuments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		raw   string
+		first string
+	}{
+		{name: "single document", raw: "resources:\n  - a.yaml\n", first: "resources:\n  - a.yaml\n"},
+		{name: "explicit start", raw: "---\nresources: []\n", first: "---\nresources: []\n"},
+		{name: "comments before the start", raw: "# overlay\n---\nresources: []\n", first: "# overlay\n---\nresources: []\n"},
+		{name: "second document", raw: "---\nresources: []\n---\nkind: ConfigMap\n", first: "---\nresources: []\n"},
+		{name: "document end marker", raw: "resources: []\n...\n", first: "resources: []\n"},
+		{name: "marker with comment", raw: "resources: []\n--- # next\nkind: x\n", first: "resources: []\n"},
+		{name: "indented dashes are content", raw: "resources:\n  ---\n", first: "resources:\n  ---\n"},
+		{name: "directives", raw: "%YAML 1.2\n---\nresources: []\n", first: "%YAML 1.2\n---\nresources: []\n"},
+		{name: "empty leading document", raw: "---\n...\n---\nresources: []\n", first: "---\n...\n---\nresources: []\n"},
+		{name: "empty leading documents before another", raw: "---\n---\nresources: []\n---\nkind: x\n", first: "---\n---\nresources: []\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			first, rest := splitDocuments([]byte(tt.raw))
+			assert.Equal(t, tt.first, string(first))
+			assert.Equal(t, tt.raw[len(tt.first):], string(rest))
+		})
+	}
+}
+
+func TestHasDocuments(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty documents", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, hasDocuments(nil))
+		assert.False(t, hasDocuments([]byte("---\n")))
+		assert.False(t, hasDocuments([]byte("...\n---\n# only a comment\n")))
+	})
+
+	t.Run("content", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, hasDocuments([]byte("---\nkind: ConfigMap\n")))
+		assert.True(t, hasDocuments([]byte("...\nkind: ConfigMap\n")))
+	})
+
+	t.Run("unparsable content", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, hasDocuments([]byte("---\nkey: [\n")))
+	})
+}
+
+func TestProcessorMultiDocument(t *testing.T) {
+	t.Parallel()
+
+	const trailer = "---\n# keep me\napiVersion: v1\nkind: ConfigMap\n"
+
+	setup := func(t *testing.T, content string) string {
+		t.Helper()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		return temp
+	}
+
+	t.Run("refuses by default", func(t *testing.T) {
+		t.Parallel()
+		content := "resources:\n  - old.yaml\n" + trailer
+		temp := setup(t, content)
+		path := filepath.Join(temp, "kustomization.yaml")
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.ErrorIs(t, err, ErrMultiDocument)
+		assert.EqualError(t, err, "parse "+path+": multi-document kustomization: another document starts at line 3 and would be lost on rewrite")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	})
+
+	t.Run("ignores trailing empty documents", func(t *testing.T) {
+		t.Parallel()
+		for _, content := range []string{"resources:\n  - app.yaml\n---\n", "resources:\n  - app.yaml\n...\n"} {
+			temp := setup(t, content)
+			proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+			_, err := proc.Process(context.Background(), temp)
+			require.NoError(t, err, content)
+		}
+	})
+
+	t.Run("manages the first document with content", func(t *testing.T) {
+		t.Parallel()
+		for _, preserve := range []bool{false, true} {
+			content := "---\n...\n---\nresources:\n  - old.yaml\n"
+			temp := setup(t, content)
+			proc := New(Options{PreserveDocuments: preserve}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+			_, err := proc.Process(context.Background(), temp)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, "---\n...\n---\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - app.yaml\n", string(data))
+		}
+	})
+
+	t.Run("preserves trailing documents when splicing", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - old.yaml\n"+trailer)
+		proc := New(Options{PreserveDocuments: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Updated)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - app.yaml\n"+trailer, string(data))
+	})
+
+	t.Run("preserves trailing documents when re-encoding", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t, "namePrefix: app-\n"+trailer)
+		proc := New(Options{PreserveDocuments: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "---\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nnamePrefix: app-\nresources:\n  - app.yaml\n"+trailer, string(data))
+	})
+
+	t.Run("plans and applies with the full file hash", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t, "resources:\n  - old.yaml\n"+trailer)
+		planner := New(Options{PreserveDocuments: true, Plan: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := planner.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.True(t, planner.Plan().PreserveDocuments)
+
+		// The plan carries the option, so apply accepts the file without the flag.
+		_, err = New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo)).Apply(context.Background(), planner.Plan())
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- app.yaml\n"+trailer)
+	})
+}
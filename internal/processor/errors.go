@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Operations reported by PathError.
+const (
+	OpReadDir = "read dir" // Listing a directory or reading its config and ignore files.
+	OpParse   = "parse"    // Reading and decoding a YAML file.
+	OpEncode  = "encode"   // Rendering an updated kustomization.
+	OpWrite   = "write"    // Replacing a kustomization on disk.
+)
+
+// PathError records the operation and path that failed while processing a tree.
+type PathError struct {
+	Op   string // One of the Op constants.
+	Path string // Directory or file the operation failed on.
+	Err  error
+}
+
+// Error renders the failure as "<op> <path>: <err>".
+// Diagnostics already lead with the path and position, so they are rendered alone.
+func (e *PathError) Error() string {
+	var diag *Diagnostic
+	if errors.As(e.Err, &diag) {
+		return e.Err.Error()
+	}
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// pathError attributes err to op on path.
+// Errors that already name a path and cancellations are returned unchanged.
+func pathError(op, path string, err error) error {
+	var pe *PathError
+	if errors.As(err, &pe) || isCancellation(err) {
+		return err
+	}
+	return &PathError{Op: op, Path: path, Err: err}
+}
+
+// isCancellation reports whether err stems from a cancelled or expired context.
+func isCancellation(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fail records a PathError and counts it when KeepGoing is set, so the walk can continue.
+// Any other error, and every error without KeepGoing, is returned to stop the run.
+func (p *Processor) fail(err error) (ResourceStats, error) {
+	var pe *PathError
+	if !p.opts.KeepGoing || !errors.As(err, &pe) {
+		return ResourceStats{}, err
+	}
+	p.logger.Error("failed", "op", pe.Op, "path", pe.Path, "error", pe.Err.Error())
+	p.state.recordError(pe)
+	return ResourceStats{Errors: 1}, nil
+}
+
+// recordError remembers a failure skipped over with KeepGoing.
+func (s *runState) recordError(err *PathError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+// Errors returns the failures recorded with KeepGoing, sorted by path.
+func (p *Processor) Errors() []error {
+	p.state.mu.Lock()
+	sorted := slices.Clone(p.state.errs)
+	p.state.mu.Unlock()
+
+	slices.SortStableFunc(sorted, func(a, b *PathError) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	errs := make([]error, 0, len(sorted))
+	for _, err := range sorted {
+		errs = append(errs, err)
+	}
+	return errs
+}
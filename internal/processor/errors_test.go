@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorKeepGoing(t *testing.T) {
+	t.Parallel()
+
+	// setup builds a tree with two malformed kustomizations next to healthy directories.
+	setup := func(t *testing.T) string {
+		t.Helper()
+		temp := t.TempDir()
+		for _, dir := range []string{"a/child", "b", "c"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(temp, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		}
+		for _, dir := range []string{"a", "b"} {
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "kustomization.yaml"), []byte("resources: [\n"), 0o644))
+		}
+		return temp
+	}
+
+	t.Run("stops at the first error by default", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t)
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.Error(t, err)
+		var pe *PathError
+		require.ErrorAs(t, err, &pe)
+		assert.Equal(t, OpParse, pe.Op)
+		assert.Equal(t, filepath.Join(temp, "a", "kustomization.yaml"), pe.Path)
+		assert.Empty(t, proc.Errors())
+	})
+
+	t.Run("records failures and continues", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t)
+		proc := New(Options{KeepGoing: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Errors)
+		assert.Equal(t, 3, stats.Updated)
+
+		// Each broken kustomization is reported once; the root still lists "a" as a resource.
+		errs := proc.Errors()
+		require.Len(t, errs, 2)
+		for i, dir := range []string{"a", "b"} {
+			var pe *PathError
+			require.ErrorAs(t, errs[i], &pe)
+			assert.Equal(t, OpParse, pe.Op)
+			assert.Equal(t, filepath.Join(temp, dir, "kustomization.yaml"), pe.Path)
+		}
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- a\n")
+
+		// Children of a failing directory are still processed.
+		for _, dir := range []string{"a/child", "c"} {
+			_, err := os.Stat(filepath.Join(temp, dir, "kustomization.yaml"))
+			assert.NoError(t, err, dir)
+		}
+	})
+
+	t.Run("collects failures from parallel subtrees", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t)
+		proc := New(Options{KeepGoing: true, Jobs: 4}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Errors)
+		require.Len(t, proc.Errors(), 2)
+		assert.Contains(t, proc.Errors()[0].Error(), "parse "+filepath.Join(temp, "a", "kustomization.yaml")+": ")
+	})
+
+	t.Run("logs each failure", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t)
+		var errOut bytes.Buffer
+		proc := New(Options{KeepGoing: true}, logging.New(io.Discard, &errOut, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Contains(t, errOut.String(), "op=parse path="+filepath.Join(temp, "b", "kustomization.yaml"))
+	})
+
+	t.Run("cancellation still stops the run", func(t *testing.T) {
+		t.Parallel()
+		temp := setup(t)
+		proc := New(Options{KeepGoing: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := proc.Process(ctx, temp)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, proc.Errors())
+	})
+}
+
+func TestPathError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("renders op and path", func(t *testing.T) {
+		t.Parallel()
+		err := pathError(OpWrite, "k.yaml", errors.New("disk full"))
+		assert.EqualError(t, err, "write k.yaml: disk full")
+	})
+
+	t.Run("keeps the innermost path", func(t *testing.T) {
+		t.Parallel()
+		inner := &PathError{Op: OpParse, Path: "child/kustomization.yaml", Err: errors.New("bad")}
+		assert.Same(t, inner, pathError(OpReadDir, "dir", inner))
+	})
+
+	t.Run("leaves cancellation alone", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, context.Canceled, pathError(OpReadDir, "dir", context.Canceled))
+	})
+}
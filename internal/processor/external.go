@@ -0,0 +1,28 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// externalReferences returns the existing local entries that point outside dir.
+// Entries are kept even when their target is missing, but dangling ones are reported.
+func (p *Processor) externalReferences(dir string, existing []string) []string {
+	var out []string
+	for _, value := range existing {
+		if !isExternalReference(value) {
+			continue
+		}
+		out = append(out, value)
+
+		target := filepath.FromSlash(value)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) {
+			p.logger.Warn("dangling reference", "kustomization", dir, "entry", value)
+		}
+	}
+	return out
+}
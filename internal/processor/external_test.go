@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorExternalReferences(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps out-of-tree entries in order", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		dir := filepath.Join(root, "overlays", "prod")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "base"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "shared"), 0o755))
+
+		errBuf := &bytes.Buffer{}
+		proc := New(Options{}, logging.New(io.Discard, errBuf, logging.LevelInfo))
+		got := proc.externalReferences(dir, []string{"../../shared", "app.yaml", "https://example.com/x", "../../base/"})
+		assert.Equal(t, []string{"../../shared", "../../base/"}, got)
+		assert.Empty(t, errBuf.String())
+	})
+
+	t.Run("warns about dangling entries", func(t *testing.T) {
+		t.Parallel()
+		errBuf := &bytes.Buffer{}
+		proc := New(Options{}, logging.New(io.Discard, errBuf, logging.LevelInfo))
+		got := proc.externalReferences(t.TempDir(), []string{"../missing"})
+		assert.Equal(t, []string{"../missing"}, got)
+		assert.Contains(t, errBuf.String(), "message=dangling reference")
+		assert.Contains(t, errBuf.String(), "entry=../missing")
+	})
+
+	t.Run("warns about entries leaving the directory only after cleaning", func(t *testing.T) {
+		t.Parallel()
+		errBuf := &bytes.Buffer{}
+		proc := New(Options{}, logging.New(io.Discard, errBuf, logging.LevelInfo))
+		got := proc.externalReferences(filepath.Join(t.TempDir(), "app"), []string{"./../missing", "app/../../gone"})
+		assert.Equal(t, []string{"./../missing", "app/../../gone"}, got)
+		assert.Contains(t, errBuf.String(), "entry=./../missing")
+		assert.Contains(t, errBuf.String(), "entry=app/../../gone")
+	})
+}
+
+func TestProcessorMergeResourcesExternal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("positions external group via order", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		dir := filepath.Join(root, "overlay")
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "base"), 0o755))
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+
+		proc := New(Options{ResourceOrder: []string{"files", "external"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		got := proc.mergeResources(dir, []string{"../base", "stale.yaml"}, []string{"sub"}, []string{"patch.yaml"}, nil)
+		assert.Equal(t, []string{"patch.yaml", "../base", "sub"}, got)
+	})
+
+	t.Run("overlay keeps base on process", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		dir := filepath.Join(root, "overlay")
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "base"), 0o755))
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		path := filepath.Join(dir, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("resources:\n  - ../base\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		upd, err := proc.updateKustomization(context.Background(), filepath.Dir(path), path, true, listing{files: []string{"app.yaml"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"../base", "app.yaml"}, upd.final)
+		assert.Equal(t, 0, upd.stats.Removed)
+	})
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gi8lino/karma/internal/glob"
+)
+
+// includeRule represents a parsed include pattern.
+type includeRule struct {
+	raw   string
+	value string // Pattern without a trailing "/"; "**" matches any number of directories.
+}
+
+// parseIncludeRules compiles CLI patterns into includeRule entries.
+func parseIncludeRules(patterns []string) []includeRule {
+	rules := make([]includeRule, 0, len(patterns))
+	for _, raw := range patterns {
+		value := strings.TrimRight(raw, "/")
+		if value == "" {
+			continue
+		}
+		rules = append(rules, includeRule{raw: raw, value: value})
+	}
+	return rules
+}
+
+// matchInclude reports whether rel is included, either directly or through an included ancestor,
+// and for directories whether they lead to included paths further down.
+// Without rules every path is included.
+func matchInclude(rel string, isDir bool, rules []includeRule) (included, leads bool) {
+	if len(rules) == 0 {
+		return true, false
+	}
+
+	// Everything below an included directory is included as well.
+	for current := rel; current != "." && current != ""; current = path.Dir(current) {
+		for _, rule := range rules {
+			if rule.matches(current) {
+				return true, false
+			}
+		}
+	}
+
+	if !isDir {
+		return false, false
+	}
+	for _, rule := range rules {
+		if !strings.Contains(rule.value, "/") || matchesBelow(rule.value, rel) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// includesDir reports whether dir is included itself rather than only leading to included paths.
+// Only included directories are fully managed; the others keep their entries and only gain new ones.
+func (p *Processor) includesDir(base, dir string) bool {
+	if len(p.includeRules) == 0 {
+		return true
+	}
+	rel := ""
+	if dir != base {
+		rel = p.relPath(base, dir)
+	}
+	included, _ := matchInclude(rel, true, p.includeRules)
+	return included
+}
+
+// keepExistingEntries adds the in-tree local entries of existing back to files and returns the
+// directories among them, so a directory that only leads to included paths never loses what it lists.
+// Entries keep their original spelling; those already in dirs or files and those referenced
+// by other fields stay out.
+func keepExistingEntries(dir string, existing, dirs, files []string, refs map[string]string) ([]string, []string) {
+	listed := make(map[string]struct{}, len(dirs)+len(files))
+	for _, name := range append(slices.Clone(dirs), files...) {
+		listed[path.Clean(name)] = struct{}{}
+	}
+
+	var kept []string
+	for _, value := range existing {
+		if isRemoteResource(value) || isExternalReference(value) {
+			continue
+		}
+		name := path.Clean(strings.TrimSuffix(value, "/"))
+		if _, ok := refs[name]; ok {
+			continue
+		}
+		if _, ok := listed[name]; ok {
+			continue
+		}
+		listed[name] = struct{}{}
+		if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil && info.IsDir() {
+			kept = append(kept, value)
+			continue
+		}
+		files = append(files, value)
+	}
+	return kept, files
+}
+
+// matches reports whether the rule selects rel; patterns without "/" also match the base name.
+func (r includeRule) matches(rel string) bool {
+	if matchValue(r.value, rel) {
+		return true
+	}
+	return !strings.Contains(r.value, "/") && glob.Match(r.value, path.Base(rel))
+}
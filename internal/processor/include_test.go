@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchInclude(t *testing.T) {
+	t.Parallel()
+
+	t.Run("everything is included without rules", func(t *testing.T) {
+		t.Parallel()
+		included, leads := matchInclude("apps/web", true, nil)
+		assert.True(t, included)
+		assert.False(t, leads)
+	})
+
+	t.Run("includes matches and their contents", func(t *testing.T) {
+		t.Parallel()
+		rules := parseIncludeRules([]string{"apps/web/"})
+		for _, rel := range []string{"apps/web", "apps/web/deploy.yaml", "apps/web/base/svc.yaml"} {
+			included, _ := matchInclude(rel, false, rules)
+			assert.True(t, included, rel)
+		}
+		included, _ := matchInclude("apps/api", true, rules)
+		assert.False(t, included)
+	})
+
+	t.Run("directories leading to matches are walked", func(t *testing.T) {
+		t.Parallel()
+		rules := parseIncludeRules([]string{"clusters/*/apps"})
+		included, leads := matchInclude("clusters", true, rules)
+		assert.False(t, included)
+		assert.True(t, leads)
+		included, leads = matchInclude("clusters/prod", true, rules)
+		assert.False(t, included)
+		assert.True(t, leads)
+		included, leads = matchInclude("clusters/prod/infra", true, rules)
+		assert.False(t, included)
+		assert.False(t, leads)
+		included, leads = matchInclude("clusters/app.yaml", false, rules)
+		assert.False(t, included)
+		assert.False(t, leads)
+	})
+
+	t.Run("doublestar leads everywhere", func(t *testing.T) {
+		t.Parallel()
+		rules := parseIncludeRules([]string{"**/prod"})
+		_, leads := matchInclude("clusters/eu", true, rules)
+		assert.True(t, leads)
+		included, _ := matchInclude("clusters/eu/prod/app.yaml", false, rules)
+		assert.True(t, included)
+	})
+
+	t.Run("name-only patterns match at any depth", func(t *testing.T) {
+		t.Parallel()
+		rules := parseIncludeRules([]string{"*-crd.yaml"})
+		included, _ := matchInclude("infra/cert-manager/cert-manager-crd.yaml", false, rules)
+		assert.True(t, included)
+		_, leads := matchInclude("infra", true, rules)
+		assert.True(t, leads)
+	})
+}
+
+func TestProcessorInclude(t *testing.T) {
+	t.Parallel()
+
+	t.Run("manages only included subtrees", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		for _, dir := range []string{"apps/web", "apps/api", "infra"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(temp, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "root.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		out := &bytes.Buffer{}
+		proc := New(Options{Include: []string{"apps/web"}}, logging.New(out, io.Discard, logging.LevelDebug))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- apps")
+		assert.NotContains(t, string(data), "infra")
+		assert.NotContains(t, string(data), "root.yaml")
+
+		data, err = os.ReadFile(filepath.Join(temp, "apps", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- web")
+		assert.NotContains(t, string(data), "- api")
+
+		data, err = os.ReadFile(filepath.Join(temp, "apps", "web", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- app.yaml")
+
+		for _, dir := range []string{"apps/api", "infra"} {
+			_, err = os.Stat(filepath.Join(temp, dir, "kustomization.yaml"))
+			assert.ErrorIs(t, err, os.ErrNotExist, dir)
+		}
+		assert.Contains(t, out.String(), "path=infra reason=include")
+	})
+
+	t.Run("keeps existing entries in leading directories", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		for _, dir := range []string{"apps/prod", "apps/dev", "infra"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(temp, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte("resources:\n  - infra\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "apps", "kustomization.yaml"), []byte("resources:\n  - dev\n"), 0o644))
+
+		proc := New(Options{Include: []string{"apps/prod"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - apps\n  - infra\n", string(data))
+
+		data, err = os.ReadFile(filepath.Join(temp, "apps", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - dev\n  - prod\n", string(data))
+
+		data, err = os.ReadFile(filepath.Join(temp, "apps", "prod", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- app.yaml")
+	})
+
+	t.Run("keeps the spelling of existing entries in leading directories", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		for _, dir := range []string{"apps/prod", "infra", "tools"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(temp, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "root.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		root := "resources:\n  - ./infra/\n  - tools/\n  - ./root.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(root), 0o644))
+
+		proc := New(Options{Include: []string{"apps/prod"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - ./infra/\n  - apps\n  - tools/\n  - ./root.yaml\n", string(data))
+	})
+
+	t.Run("skip patterns win over include patterns", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "apps", "web"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "apps", "web", "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "apps", "web", "test.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+
+		proc := New(Options{Include: []string{"apps/**"}, Skip: []string{"test.yaml"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "apps", "web", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- app.yaml")
+		assert.NotContains(t, string(data), "test.yaml")
+	})
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gi8lino/karma/internal/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// typeMeta identifies the Kubernetes type of a YAML document.
+type typeMeta struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+}
+
+// String renders the type as apiVersion/Kind.
+func (t typeMeta) String() string {
+	if t.APIVersion == "" {
+		return t.Kind
+	}
+	return t.APIVersion + "/" + t.Kind
+}
+
+// kindRule represents a parsed [apiVersion/]Kind pattern.
+type kindRule struct {
+	raw        string
+	apiVersion string // Glob for the apiVersion; empty matches any.
+	kind       string // Glob for the kind.
+}
+
+// parseKindRules compiles CLI patterns into kindRule entries.
+// The kind follows the last "/", so "Secret", "v1/Secret", and "kustomize.toolkit.fluxcd.io/*/Kustomization" are valid.
+func parseKindRules(patterns []string) []kindRule {
+	rules := make([]kindRule, 0, len(patterns))
+	for _, raw := range patterns {
+		rule := kindRule{raw: raw, kind: raw}
+		if i := strings.LastIndexByte(raw, '/'); i >= 0 {
+			rule.apiVersion, rule.kind = raw[:i], raw[i+1:]
+		}
+		if rule.kind == "" {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matches reports whether the rule selects the document type.
+func (r kindRule) matches(meta typeMeta) bool {
+	if !glob.Match(r.kind, meta.Kind) {
+		return false
+	}
+	return r.apiVersion == "" || glob.Match(r.apiVersion, meta.APIVersion)
+}
+
+// readTypeMetas returns the type of every non-empty document in the YAML file at path.
+// A file that does not decode is reported as a PathError with OpParse.
+func readTypeMetas(path string) ([]typeMeta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []typeMeta
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return metas, nil
+			}
+			return nil, &PathError{Op: OpParse, Path: path, Err: err}
+		}
+		// Skip empty documents such as a trailing "---".
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+		var meta typeMeta
+		if err := doc.Decode(&meta); err != nil {
+			// Documents that are not mappings have no type.
+			metas = append(metas, typeMeta{})
+			continue
+		}
+		metas = append(metas, meta)
+	}
+}
+
+// needsTypes reports whether YAML files must be parsed to decide whether they are listed.
+func (p *Processor) needsTypes() bool {
+	return p.opts.ManifestsOnly || len(p.skipKinds) > 0 || len(p.includeKinds) > 0
+}
+
+// matchKind decides whether a YAML file with the given document types is withheld by the kind rules.
+// A file is skipped when any document matches a skip rule, or when include rules exist and no document matches one.
+// The returned string names the type that decided, for logging.
+func (p *Processor) matchKind(metas []typeMeta) (skip bool, kind string) {
+	for _, meta := range metas {
+		for _, rule := range p.skipKinds {
+			if rule.matches(meta) {
+				return true, meta.String()
+			}
+		}
+	}
+	if len(p.includeKinds) == 0 {
+		return false, ""
+	}
+	for _, meta := range metas {
+		for _, rule := range p.includeKinds {
+			if rule.matches(meta) {
+				return false, ""
+			}
+		}
+	}
+
+	kinds := make([]string, 0, len(metas))
+	for _, meta := range metas {
+		kinds = append(kinds, meta.String())
+	}
+	return true, strings.Join(kinds, ",")
+}
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKindRules(t *testing.T) {
+	t.Parallel()
+
+	t.Run("splits apiVersion and kind", func(t *testing.T) {
+		t.Parallel()
+		rules := parseKindRules([]string{"Secret", "v1/ConfigMap", "kustomize.toolkit.fluxcd.io/*/Kustomization", "apps/"})
+		assert.Equal(t, []kindRule{
+			{raw: "Secret", kind: "Secret"},
+			{raw: "v1/ConfigMap", apiVersion: "v1", kind: "ConfigMap"},
+			{raw: "kustomize.toolkit.fluxcd.io/*/Kustomization", apiVersion: "kustomize.toolkit.fluxcd.io/*", kind: "Kustomization"},
+		}, rules)
+	})
+
+	t.Run("matches globs", func(t *testing.T) {
+		t.Parallel()
+		rules := parseKindRules([]string{"kustomize.toolkit.fluxcd.io/*/Kustomization", "*Binding"})
+		assert.True(t, rules[0].matches(typeMeta{APIVersion: "kustomize.toolkit.fluxcd.io/v1", Kind: "Kustomization"}))
+		assert.False(t, rules[0].matches(typeMeta{APIVersion: "kustomize.config.k8s.io/v1beta1", Kind: "Kustomization"}))
+		assert.True(t, rules[1].matches(typeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"}))
+	})
+}
+
+func TestReadTypeMetas(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads every document", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "all.yaml")
+		content := "apiVersion: v1\nkind: Secret\n---\n---\napiVersion: apps/v1\nkind: Deployment\n---\n- a\n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+		metas, err := readTypeMetas(path)
+		require.NoError(t, err)
+		assert.Equal(t, []typeMeta{
+			{APIVersion: "v1", Kind: "Secret"},
+			{APIVersion: "apps/v1", Kind: "Deployment"},
+			{},
+		}, metas)
+	})
+
+	t.Run("reports parse errors", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "broken.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("kind: [\n"), 0o644))
+
+		_, err := readTypeMetas(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "parse "+path)
+	})
+}
+
+func TestScanEntriesKinds(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T) string {
+		t.Helper()
+		temp := t.TempDir()
+		files := map[string]string{
+			"deploy.yaml": "apiVersion: apps/v1\nkind: Deployment\n",
+			"secret.yaml": "apiVersion: v1\nkind: Secret\n",
+			"mixed.yaml":  "apiVersion: v1\nkind: Service\n---\napiVersion: v1\nkind: Secret\n",
+			"flux.yaml":   "apiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\n",
+		}
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(temp, name), []byte(content), 0o644))
+		}
+		return temp
+	}
+
+	t.Run("skips matching kinds in any document", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		out := &bytes.Buffer{}
+		proc := New(Options{SkipKinds: []string{"Secret", "kustomize.toolkit.fluxcd.io/*/Kustomization"}},
+			logging.New(out, io.Discard, logging.LevelDebug))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deploy.yaml"}, fileEntries)
+		assert.Contains(t, out.String(), "path=secret.yaml reason=kind kind=v1/Secret")
+	})
+
+	t.Run("includes files with a matching document", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		proc := New(Options{IncludeKinds: []string{"Deployment", "Service"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deploy.yaml", "mixed.yaml"}, fileEntries)
+	})
+
+	t.Run("files that do not parse match no kind", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "template.yaml"), []byte("kind: [\n"), 0o644))
+
+		out := &bytes.Buffer{}
+		proc := New(Options{IncludeKinds: []string{"Deployment"}}, logging.New(out, io.Discard, logging.LevelDebug))
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deploy.yaml"}, fileEntries)
+		assert.Contains(t, out.String(), "path=template.yaml reason=kind kind= error=")
+
+		proc = New(Options{SkipKinds: []string{"Secret"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, fileEntries, _, err = proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deploy.yaml", "flux.yaml", "template.yaml"}, fileEntries)
+	})
+
+	t.Run("skip wins over include", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		proc := New(Options{IncludeKinds: []string{"Service"}, SkipKinds: []string{"Secret"}},
+			logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Empty(t, fileEntries)
+	})
+}
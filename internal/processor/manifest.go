@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"slices"
+
+	"github.com/gi8lino/karma/internal/glob"
+)
+
+// wellKnownNonResourceFiles are the names of YAML files that are never Kubernetes resources.
+// With ManifestsOnly they are skipped without parsing.
+var wellKnownNonResourceFiles = []string{
+	"Chart.yaml",
+	"values.yaml",
+	"values.yml",
+	".sops.yaml",
+	"kustomizeconfig.yaml",
+	"renovate.yaml",
+}
+
+// isNonResourceFile reports whether name matches one of the configured non-resource file globs,
+// or with ManifestsOnly one of the well-known non-resource names.
+func (p *Processor) isNonResourceFile(name string) bool {
+	for _, pattern := range p.opts.NonResourceFiles {
+		if pattern != "" && glob.Match(pattern, name) {
+			return true
+		}
+	}
+	return p.opts.ManifestsOnly && slices.Contains(wellKnownNonResourceFiles, name)
+}
+
+// isManifest reports whether every document has an apiVersion and a kind.
+// Files without any document are not manifests.
+func isManifest(metas []typeMeta) bool {
+	if len(metas) == 0 {
+		return false
+	}
+	for _, meta := range metas {
+		if meta.APIVersion == "" || meta.Kind == "" {
+			return false
+		}
+	}
+	return true
+}
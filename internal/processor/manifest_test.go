@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsManifest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requires apiVersion and kind in every document", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, isManifest([]typeMeta{{APIVersion: "v1", Kind: "Service"}}))
+		assert.False(t, isManifest([]typeMeta{{APIVersion: "v1", Kind: "Service"}, {Kind: "Deployment"}}))
+		assert.False(t, isManifest(nil))
+	})
+}
+
+func TestScanEntriesNonManifests(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T) string {
+		t.Helper()
+		temp := t.TempDir()
+		files := map[string]string{
+			"app.yaml":        "apiVersion: v1\nkind: ConfigMap\n",
+			"Chart.yaml":      "apiVersion: v2\nname: demo\n",
+			"values-dev.yaml": "replicas: 1\n",
+			"settings.yaml":   "apiVersion: v1\nkind: ConfigMap\n---\nfoo: bar\n",
+		}
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(temp, name), []byte(content), 0o644))
+		}
+		return temp
+	}
+
+	t.Run("lists well-known names by default", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "values.yaml"), []byte("replicas: 1\n"), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Chart.yaml", "app.yaml", "settings.yaml", "values-dev.yaml", "values.yaml"}, fileEntries)
+	})
+
+	t.Run("skips well-known names with the content check", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "values.yaml"), []byte("{{ broken"), 0o644))
+		out := &bytes.Buffer{}
+		proc := New(Options{ManifestsOnly: true}, logging.New(out, io.Discard, logging.LevelDebug))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app.yaml"}, fileEntries)
+		assert.Contains(t, out.String(), "path=Chart.yaml reason=non-manifest")
+		assert.Contains(t, out.String(), "path=values.yaml reason=non-manifest\n")
+	})
+
+	t.Run("content check drops files with untyped documents", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		out := &bytes.Buffer{}
+		proc := New(Options{ManifestsOnly: true}, logging.New(out, io.Discard, logging.LevelDebug))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app.yaml"}, fileEntries)
+		assert.Contains(t, out.String(), "path=settings.yaml reason=non-manifest")
+		assert.Contains(t, out.String(), "path=values-dev.yaml reason=non-manifest")
+	})
+
+	t.Run("templates that do not parse are not manifests", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "deploy.yaml"), []byte("apiVersion: apps/v1\nkind: Deployment\nspec:\n  resources:\n    {{- toYaml .Values.resources | nindent 4 }}\n"), 0o644))
+		out := &bytes.Buffer{}
+		proc := New(Options{ManifestsOnly: true}, logging.New(out, io.Discard, logging.LevelDebug))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app.yaml"}, fileEntries)
+		assert.Contains(t, out.String(), "path=deploy.yaml reason=non-manifest error=")
+	})
+
+	t.Run("globs extend the name list", func(t *testing.T) {
+		t.Parallel()
+		temp := write(t)
+		proc := New(Options{NonResourceFiles: []string{"values-*.yaml", ""}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, fileEntries, _, err := proc.scanEntries(temp, temp, matchers{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Chart.yaml", "app.yaml", "settings.yaml"}, fileEntries)
+	})
+}
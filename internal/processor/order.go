@@ -3,13 +3,15 @@ package processor
 import "strings"
 
 const (
-	resourceGroupRemote = "remote"
-	resourceGroupDirs   = "dirs"
-	resourceGroupFiles  = "files"
+	resourceGroupRemote   = "remote"
+	resourceGroupExternal = "external"
+	resourceGroupDirs     = "dirs"
+	resourceGroupFiles    = "files"
 )
 
 var defaultResourceOrder = []string{
 	resourceGroupRemote,
+	resourceGroupExternal,
 	resourceGroupDirs,
 	resourceGroupFiles,
 }
@@ -45,7 +47,7 @@ func normalizeResourceOrder(parts []string) []string {
 			continue
 		}
 		switch group {
-		case resourceGroupRemote, resourceGroupDirs, resourceGroupFiles:
+		case resourceGroupRemote, resourceGroupExternal, resourceGroupDirs, resourceGroupFiles:
 		default:
 			continue
 		}
@@ -11,7 +11,7 @@ func TestDefaultResourceOrder(t *testing.T) {
 
 	t.Run("returns default", func(t *testing.T) {
 		t.Parallel()
-		assert.Equal(t, []string{"remote", "dirs", "files"}, DefaultResourceOrder())
+		assert.Equal(t, []string{"remote", "external", "dirs", "files"}, DefaultResourceOrder())
 	})
 }
 
@@ -20,17 +20,17 @@ func TestParseResourceOrder(t *testing.T) {
 
 	t.Run("default order", func(t *testing.T) {
 		t.Parallel()
-		assert.Equal(t, []string{"remote", "dirs", "files"}, ParseResourceOrder(""))
+		assert.Equal(t, []string{"remote", "external", "dirs", "files"}, ParseResourceOrder(""))
 	})
 
 	t.Run("partial order appends missing groups", func(t *testing.T) {
 		t.Parallel()
-		assert.Equal(t, []string{"remote", "files", "dirs"}, ParseResourceOrder("remote,files"))
+		assert.Equal(t, []string{"remote", "files", "external", "dirs"}, ParseResourceOrder("remote,files"))
 	})
 
 	t.Run("dedups invalid entries", func(t *testing.T) {
 		t.Parallel()
-		assert.Equal(t, []string{"remote", "dirs", "files"}, ParseResourceOrder("remote,remote,invalid"))
+		assert.Equal(t, []string{"remote", "external", "dirs", "files"}, ParseResourceOrder("remote,remote,invalid"))
 	})
 }
 
@@ -39,30 +39,30 @@ func TestNormalizeResourceOrder(t *testing.T) {
 
 	t.Run("empty slice returns default", func(t *testing.T) {
 		t.Parallel()
-		assert.Equal(t, []string{"remote", "dirs", "files"}, normalizeResourceOrder([]string{}))
+		assert.Equal(t, []string{"remote", "external", "dirs", "files"}, normalizeResourceOrder([]string{}))
 	})
 
 	t.Run("ignores unknown entries and trims whitespace", func(t *testing.T) {
 		t.Parallel()
 		got := normalizeResourceOrder([]string{"  DIRS", "foo", "FILES"})
-		assert.Equal(t, []string{"dirs", "files", "remote"}, got)
+		assert.Equal(t, []string{"dirs", "files", "remote", "external"}, got)
 	})
 
 	t.Run("dedups repeated entries", func(t *testing.T) {
 		t.Parallel()
 		got := normalizeResourceOrder([]string{"remote", "remote", "dirs"})
-		assert.Equal(t, []string{"remote", "dirs", "files"}, got)
+		assert.Equal(t, []string{"remote", "dirs", "external", "files"}, got)
 	})
 
 	t.Run("maintains custom order when valid", func(t *testing.T) {
 		t.Parallel()
 		got := normalizeResourceOrder([]string{"files", "remote"})
-		assert.Equal(t, []string{"files", "remote", "dirs"}, got)
+		assert.Equal(t, []string{"files", "remote", "external", "dirs"}, got)
 	})
 
 	t.Run("empty group", func(t *testing.T) {
 		t.Parallel()
 		got := normalizeResourceOrder([]string{"remote", "remote", "", "dirs"})
-		assert.Equal(t, []string{"remote", "dirs", "files"}, got)
+		assert.Equal(t, []string{"remote", "dirs", "external", "files"}, got)
 	})
 }
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorJobs(t *testing.T) {
+	t.Parallel()
+
+	// writeTree creates a few levels of directories with manifests and ignore files.
+	writeTree := func(t *testing.T) string {
+		t.Helper()
+		temp := t.TempDir()
+		for i := range 4 {
+			for j := range 3 {
+				dir := filepath.Join(temp, fmt.Sprintf("app-%d", i), fmt.Sprintf("env-%d", j))
+				require.NoError(t, os.MkdirAll(dir, 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.yaml"), []byte("kind: Deployment\n"), 0o644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "skip.yaml"), []byte("kind: Secret\n"), 0o644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, DefaultIgnoreFile), []byte("ignored.yaml\n"), 0o644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.yaml"), []byte("kind: Job\n"), 0o644))
+			}
+		}
+		return temp
+	}
+
+	run := func(t *testing.T, opts Options, dirs []string) (string, ResourceStats, *Processor) {
+		t.Helper()
+		out := &bytes.Buffer{}
+		proc := New(opts, logging.New(out, out, logging.LevelTrace))
+		stats, err := proc.ProcessAll(context.Background(), dirs)
+		require.NoError(t, err)
+		return out.String(), stats, proc
+	}
+
+	t.Run("output matches a sequential run", func(t *testing.T) {
+		t.Parallel()
+		first, second := writeTree(t), writeTree(t)
+		opts := Options{Skip: []string{"skip.yaml"}, IgnoreFile: DefaultIgnoreFile, Plan: true, Diff: true}
+
+		seqOut, seqStats, seqProc := run(t, opts, []string{first, second})
+		opts.Jobs = 8
+		parOut, parStats, parProc := run(t, opts, []string{first, second})
+
+		assert.Equal(t, seqOut, parOut)
+		assert.Equal(t, seqStats, parStats)
+		assert.Equal(t, 34, parStats.Updated)
+		assert.Equal(t, seqProc.Plan(), parProc.Plan())
+	})
+
+	t.Run("reports unused skip patterns in walk order", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		var want []UnusedSkipPattern
+		for i := range 12 {
+			dir := filepath.Join(temp, fmt.Sprintf("app-%02d", i))
+			require.NoError(t, os.MkdirAll(dir, 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.yaml"), []byte("kind: Deployment\n"), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte("skip: [missing.yaml]\n"), 0o644))
+			want = append(want, UnusedSkipPattern{Pattern: "missing.yaml", Source: filepath.Join(dir, ConfigFileName)})
+		}
+
+		_, _, seqProc := run(t, Options{Skip: []string{"none.yaml"}, UseConfig: true, Plan: true}, []string{temp})
+		_, _, parProc := run(t, Options{Skip: []string{"none.yaml"}, UseConfig: true, Plan: true, Jobs: 8}, []string{temp})
+
+		want = append([]UnusedSkipPattern{{Pattern: "none.yaml", Source: "--skip"}}, want...)
+		assert.Equal(t, want, seqProc.UnusedSkipPatterns())
+		assert.Equal(t, want, parProc.UnusedSkipPatterns())
+	})
+
+	t.Run("writes the same files", func(t *testing.T) {
+		t.Parallel()
+		temp := writeTree(t)
+		_, stats, _ := run(t, Options{Jobs: 3, IgnoreFile: DefaultIgnoreFile}, []string{temp})
+		assert.Equal(t, 17, stats.Updated)
+
+		data, err := os.ReadFile(filepath.Join(temp, "app-2", "env-1", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- deploy.yaml")
+		assert.NotContains(t, string(data), "ignored.yaml")
+
+		_, stats, _ = run(t, Options{Jobs: 3, IgnoreFile: DefaultIgnoreFile}, []string{temp})
+		assert.Equal(t, 17, stats.NoOp)
+	})
+
+	t.Run("returns the first error in walk order", func(t *testing.T) {
+		t.Parallel()
+		temp := writeTree(t)
+		broken := []string{filepath.Join(temp, "app-1", "env-1"), filepath.Join(temp, "app-3", "env-1")}
+		for _, dir := range broken {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: [\n"), 0o644))
+		}
+
+		out := &bytes.Buffer{}
+		proc := New(Options{Jobs: 4}, logging.New(out, out, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), filepath.Join(temp, "app-1"))
+
+		// Siblings that ran before they were stopped must still report what they wrote.
+		written := proc.Written()
+		require.NoError(t, filepath.WalkDir(temp, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.Name() != "kustomization.yaml" || slices.Contains(broken, filepath.Dir(path)) {
+				return err
+			}
+			assert.Contains(t, written, path)
+			assert.Contains(t, out.String(), "kustomization="+path)
+			return nil
+		}))
+
+		// Subtrees before the failure are finished, as in a sequential run.
+		assert.Contains(t, written, filepath.Join(temp, "app-0", "env-2", "kustomization.yaml"))
+	})
+
+	t.Run("stops handing out tasks after a failure", func(t *testing.T) {
+		t.Parallel()
+		temp := writeTree(t)
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app-0", "kustomization.yaml"), []byte("resources: [\n"), 0o644))
+
+		// The failing task runs on the calling goroutine because the only worker is taken.
+		proc := New(Options{Jobs: 2}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		proc.state.workers <- struct{}{}
+		_, err := proc.runAll(context.Background(), []task{
+			func(ctx context.Context, proc *Processor) (ResourceStats, error) {
+				return proc.Process(ctx, filepath.Join(temp, "app-0"))
+			},
+			func(ctx context.Context, proc *Processor) (ResourceStats, error) {
+				return proc.Process(ctx, filepath.Join(temp, "app-1"))
+			},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), filepath.Join(temp, "app-0"))
+		assert.Empty(t, proc.Written())
+		_, err = os.Stat(filepath.Join(temp, "app-1", "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
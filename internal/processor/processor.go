@@ -10,24 +10,46 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/gi8lino/karma/internal/diff"
 	"github.com/gi8lino/karma/internal/gitignore"
 	"github.com/gi8lino/karma/internal/logging"
+	"github.com/gi8lino/karma/internal/plan"
 	"github.com/gi8lino/karma/internal/utils"
 	"gopkg.in/yaml.v3"
 )
 
 // Options describe how the processor behaves for each tree.
 type Options struct {
-	ResourceOrder   []string
-	Skip            []string
-	UseGitIgnore    bool
-	IncludeDot      bool
-	AddDirSuffix    bool
-	AddDirPrefix    bool
-	IgnoredPrefixes []string
+	ResourceOrder     []string
+	Skip              []string
+	Include           []string // Only walk and list paths matching these patterns; empty includes everything.
+	SkipKinds         []string // Leave out YAML files holding a document of these [apiVersion/]Kind patterns.
+	IncludeKinds      []string // Only list YAML files holding a document of these [apiVersion/]Kind patterns.
+	ManifestsOnly     bool     // Only list YAML files whose documents all have apiVersion and kind.
+	NonResourceFiles  []string // File name globs that are never listed, e.g. Helm values files.
+	UseGitIgnore      bool
+	IncludeDot        bool
+	AddDirSuffix      bool
+	AddDirPrefix      bool
+	IgnoredPrefixes   []string
+	UseConfig         bool   // Discover .karma.yaml files while walking.
+	Check             bool   // Report drift without writing any file.
+	DryRun            bool   // Log changes without writing any file.
+	Diff              bool   // Print a unified diff for every changed kustomization.
+	Plan              bool   // Record changes in a plan instead of writing them.
+	IgnoreFile        string // Per-directory ignore file with gitignore syntax; empty disables it.
+	Jobs              int    // Directories processed concurrently; values below 2 walk sequentially.
+	KeepGoing         bool   // Record failing paths and continue the walk instead of stopping at the first.
+	Strict            bool   // Refuse kustomizations whose structure would have to be coerced or dropped.
+	PreserveDocuments bool   // Update the first document of multi-document kustomizations and keep the rest verbatim.
 }
 
+// DefaultIgnoreFile is the karma-specific ignore file read in every directory.
+const DefaultIgnoreFile = ".karmaignore"
+
 var defaultDirSlashIgnorePrefixes = []string{
 	"http://",
 	"https://",
@@ -50,6 +72,7 @@ type ResourceStats struct {
 	Removed   int
 	Updated   int
 	NoOp      int
+	Errors    int // Paths that failed with KeepGoing.
 }
 
 // Add adds the other stats to this one.
@@ -59,73 +82,352 @@ func (s *ResourceStats) Add(other ResourceStats) {
 	s.Removed += other.Removed
 	s.Updated += other.Updated
 	s.NoOp += other.NoOp
+	s.Errors += other.Errors
 }
 
 // Processor walks directories and keeps kustomization resources in sync.
 type Processor struct {
-	opts      Options
-	logger    *logging.Logger
-	skipRules []skipRule
+	opts         Options
+	logger       *logging.Logger
+	skipRules    []skipRule
+	includeRules []includeRule
+	skipKinds    []kindRule
+	includeKinds []kindRule
+	plan         *planBuffer     // Changes recorded in plan mode; forked processors record into their own.
+	declared     *skipRuleBuffer // Skip rules in the order they were declared; forked processors record into their own.
+	state        *runState       // Shared by every processor derived for subtrees.
+}
+
+// runState collects results across all trees processed by a processor.
+type runState struct {
+	mu        sync.Mutex
+	skipRules []skipRule    // Every skip rule seen, once per source and pattern, to share usage between copies.
+	written   []string      // Kustomizations written to disk.
+	errs      []*PathError  // Failures skipped over with KeepGoing.
+	workers   chan struct{} // Tokens for extra goroutines; nil walks sequentially.
+}
+
+// planBuffer collects planned changes in walk order.
+type planBuffer struct {
+	entries []plan.Entry
+}
+
+// skipRuleBuffer collects skip rules in walk order for the unused pattern report.
+type skipRuleBuffer struct {
+	rules []skipRule
+}
+
+// registerSkipRules remembers rules for the unused pattern report.
+// Rules seen before, e.g. from a config file visited again, share the usage of the first copy.
+func (s *runState) registerSkipRules(rules []skipRule) []skipRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range rules {
+		known := slices.IndexFunc(s.skipRules, func(r skipRule) bool {
+			return r.source == rule.source && r.raw == rule.raw
+		})
+		if known >= 0 {
+			rules[i].used = s.skipRules[known].used
+			continue
+		}
+		s.skipRules = append(s.skipRules, rule)
+	}
+	return rules
+}
+
+// recordWritten remembers a kustomization written to disk.
+func (s *runState) recordWritten(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, path)
+}
+
+// record appends a planned change for path.
+func (b *planBuffer) record(path string, upd kustomizationUpdate) {
+	entry := plan.Entry{
+		Path:   path,
+		Exists: upd.existed,
+		Old:    upd.order,
+		New:    upd.final,
+
+		OldComponents: upd.compOld,
+		NewComponents: upd.compNew,
+	}
+	if upd.existed {
+		entry.Hash = plan.Hash(upd.before)
+	}
+	b.entries = append(b.entries, entry)
 }
 
 // New creates a processor with the provided options and logger.
 func New(opts Options, logger *logging.Logger) *Processor {
+	state := &runState{}
+	if opts.Jobs > 1 {
+		// The calling goroutine is a worker too.
+		state.workers = make(chan struct{}, opts.Jobs-1)
+	}
+	skipRules := state.registerSkipRules(parseSkipRules(opts.Skip))
 	return &Processor{
-		opts:      opts,
-		logger:    logger,
-		skipRules: parseSkipRules(opts.Skip),
+		opts:         opts,
+		logger:       logger,
+		skipRules:    skipRules,
+		includeRules: parseIncludeRules(opts.Include),
+		skipKinds:    parseKindRules(opts.SkipKinds),
+		includeKinds: parseKindRules(opts.IncludeKinds),
+		plan:         &planBuffer{},
+		declared:     &skipRuleBuffer{rules: slices.Clone(skipRules)},
+		state:        state,
 	}
 }
 
+// Plan returns the changes recorded so far in plan mode.
+func (p *Processor) Plan() plan.Plan {
+	return plan.Plan{
+		Version: plan.Version,
+		Entries: slices.Clone(p.plan.entries),
+
+		PreserveDocuments: p.opts.PreserveDocuments,
+	}
+}
+
+// UnusedSkipPatterns returns the skip patterns that never matched a path, in declaration order.
+// Config files are ordered by the walk, so the report is the same with any number of jobs.
+func (p *Processor) UnusedSkipPatterns() []UnusedSkipPattern {
+	var unused []UnusedSkipPattern
+	reported := make(map[*atomic.Bool]bool)
+	for _, rule := range p.declared.rules {
+		// Copies of a rule from a config file visited again share their usage.
+		if reported[rule.used] {
+			continue
+		}
+		reported[rule.used] = true
+		if !rule.used.Load() {
+			unused = append(unused, UnusedSkipPattern{Pattern: rule.raw, Source: rule.source})
+		}
+	}
+	return unused
+}
+
+// Written returns the kustomizations written so far, sorted by path.
+// After an aborted run it tells which files already carry their update.
+func (p *Processor) Written() []string {
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	written := slices.Clone(p.state.written)
+	slices.Sort(written)
+	return written
+}
+
 // Process walks a directory tree and updates kustomizations incrementally.
 func (p *Processor) Process(ctx context.Context, dir string) (ResourceStats, error) {
-	return p.walkDir(ctx, dir, dir, nil, false)
+	return p.walkDir(ctx, dir, dir, matchers{}, false)
+}
+
+// ProcessAll processes each base directory in turn, or concurrently when Jobs allows it.
+func (p *Processor) ProcessAll(ctx context.Context, dirs []string) (ResourceStats, error) {
+	tasks := make([]task, 0, len(dirs))
+	for _, dir := range dirs {
+		tasks = append(tasks, func(ctx context.Context, proc *Processor) (ResourceStats, error) {
+			proc.logger.Processing("base", "path", dir)
+			return proc.Process(ctx, dir)
+		})
+	}
+	return p.runAll(ctx, tasks)
+}
+
+// task processes part of a tree with the given processor.
+type task func(ctx context.Context, proc *Processor) (ResourceStats, error)
+
+// runAll runs tasks and sums their stats, stopping at the first error.
+// With workers, tasks run concurrently on forked processors whose logs and plans
+// are merged in task order, so the output matches a sequential run.
+// A failing task cancels the running tasks after it and no further tasks start,
+// while earlier tasks finish as they would have sequentially;
+// the logs of every task that ran are kept, so each file written is reported.
+func (p *Processor) runAll(ctx context.Context, tasks []task) (ResourceStats, error) {
+	var stats ResourceStats
+	if p.state.workers == nil {
+		for _, run := range tasks {
+			taskStats, err := run(ctx, p)
+			if err != nil {
+				return ResourceStats{}, err
+			}
+			stats.Add(taskStats)
+		}
+		return stats, nil
+	}
+
+	type result struct {
+		proc   *Processor
+		cancel context.CancelFunc
+		stats  ResourceStats
+		err    error
+	}
+	results := make([]result, len(tasks))
+	var (
+		mu      sync.Mutex
+		started int
+		failed  = len(tasks) // Index of the earliest failed task.
+		wg      sync.WaitGroup
+	)
+	// stopAfter cancels the tasks a sequential run would not have reached once task i failed.
+	stopAfter := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if i >= failed {
+			return
+		}
+		failed = i
+		for j := i + 1; j < started; j++ {
+			results[j].cancel()
+		}
+	}
+	for i, run := range tasks {
+		mu.Lock()
+		if failed < len(tasks) {
+			mu.Unlock()
+			break
+		}
+		taskCtx, cancel := context.WithCancel(ctx)
+		res := &results[i]
+		res.proc, res.cancel = p.fork(), cancel
+		started++
+		mu.Unlock()
+
+		// Hand the task to a free worker, or run it here when all are busy.
+		select {
+		case p.state.workers <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-p.state.workers }()
+				if res.stats, res.err = run(taskCtx, res.proc); res.err != nil {
+					stopAfter(i)
+				}
+			}()
+			continue
+		default:
+		}
+		if res.stats, res.err = run(taskCtx, res.proc); res.err != nil {
+			stopAfter(i)
+		}
+	}
+	wg.Wait()
+
+	// Tasks after the first failure only return their cancellation, so the first error in task order is the real one.
+	var firstErr error
+	for _, res := range results[:started] {
+		res.cancel()
+		res.proc.logger.Flush()
+		p.plan.entries = append(p.plan.entries, res.proc.plan.entries...)
+		p.declared.rules = append(p.declared.rules, res.proc.declared.rules...)
+		if res.err == nil {
+			stats.Add(res.stats)
+			continue
+		}
+		if firstErr == nil {
+			firstErr = res.err
+		}
+	}
+	if firstErr != nil {
+		return ResourceStats{}, firstErr
+	}
+	return stats, nil
+}
+
+// fork returns a copy of the processor that buffers its logs, plan, and skip rules until runAll merges them.
+func (p *Processor) fork() *Processor {
+	forked := *p
+	forked.logger = p.logger.Buffered()
+	forked.plan = &planBuffer{}
+	forked.declared = &skipRuleBuffer{}
+	return &forked
+}
+
+// matchers holds the ignore matchers that apply to a directory.
+type matchers struct {
+	git   gitignore.Matcher // .gitignore chain; nil when disabled.
+	karma gitignore.Matcher // Ignore file chain; nil when disabled.
 }
 
 // walkDir processes the current directory and recurses into children.
-func (p *Processor) walkDir(ctx context.Context, dir, base string, parent gitignore.Matcher, skipUpdate bool) (ResourceStats, error) {
-	// Load the matcher once so it can be reused for each directory.
-	matcher, err := p.loadMatcher(dir, parent)
-	if err != nil {
+func (p *Processor) walkDir(ctx context.Context, dir, base string, parent matchers, skipUpdate bool) (ResourceStats, error) {
+	// Stop between directories once the run was cancelled.
+	if err := ctx.Err(); err != nil {
 		return ResourceStats{}, err
 	}
 
-	// Load the entries once so scanEntries can handle ignores and skip logic.
-	dirEntries, fileEntries, subdirs, err := p.scanEntries(dir, base, matcher)
+	// Apply the directory configuration before anything else reads the options.
+	proc, err := p.withDirConfig(dir, base)
 	if err != nil {
-		return ResourceStats{}, err
+		return p.fail(pathError(OpReadDir, dir, err))
 	}
 
-	// Resolve which kustomization file should be touched (yaml or yml).
-	kustomizationPath, exists, pathErr := p.pickKustomizationPath(dir)
-	if pathErr != nil {
-		return ResourceStats{}, pathErr
+	// Load the matchers once so they can be reused for each directory.
+	matcher, err := proc.loadMatcher(dir, parent)
+	if err != nil {
+		return proc.fail(pathError(OpReadDir, dir, err))
 	}
 
-	var stats ResourceStats
+	// Load the entries once so scanEntries can handle ignores and skip logic.
+	dirEntries, fileEntries, subdirs, err := proc.scanEntries(dir, base, matcher)
+	if err != nil {
+		return proc.fail(pathError(OpReadDir, dir, err))
+	}
 
-	// Rewrite the kustomization file if it changed.
-	fileStats, err := p.applyKustomization(dir, kustomizationPath, exists, dirEntries, fileEntries, skipUpdate)
+	// Rewrite the kustomization file if it changed; a failure does not hide the children with KeepGoing.
+	stats, referenced, err := proc.syncDir(ctx, dir, base, matcher, dirEntries, fileEntries, skipUpdate)
 	if err != nil {
-		return ResourceStats{}, err
+		if stats, err = proc.fail(err); err != nil {
+			return ResourceStats{}, err
+		}
 	}
-	stats.Add(fileStats)
 
 	// Recurse into each child unless marked as "skipWalk".
+	tasks := make([]task, 0, len(subdirs))
 	for _, child := range subdirs {
-		if child.skipWalk {
+		if child.skipWalk || slices.Contains(referenced, child.name) {
 			continue
 		}
-		childStats, err := p.walkDir(ctx, filepath.Join(dir, child.name), base, matcher, child.skipUpdate)
-		if err != nil {
-			return ResourceStats{}, err
-		}
-		stats.Add(childStats)
+		tasks = append(tasks, func(ctx context.Context, proc *Processor) (ResourceStats, error) {
+			return proc.walkDir(ctx, filepath.Join(dir, child.name), base, matcher, child.skipUpdate)
+		})
 	}
+	childStats, err := proc.runAll(ctx, tasks)
+	if err != nil {
+		return ResourceStats{}, err
+	}
+	stats.Add(childStats)
 
 	return stats, nil
 }
 
+// syncDir brings the kustomization in dir in line with the scanned entries.
+// It also returns the subdirectories left out because other fields reference their files.
+func (p *Processor) syncDir(
+	ctx context.Context,
+	dir, base string,
+	matcher matchers,
+	dirEntries, fileEntries []string,
+	skipUpdate bool,
+) (ResourceStats, []string, error) {
+	// Route Component directories into the components block.
+	entries, err := p.splitComponents(dir, dirEntries, fileEntries)
+	if err != nil {
+		return ResourceStats{}, nil, pathError(OpReadDir, dir, err)
+	}
+	entries.keepExisting = !p.includesDir(base, dir)
+	entries.matcher = matcher
+
+	// Resolve which kustomization file should be touched (yaml or yml).
+	kustomizationPath, exists, err := p.pickKustomizationPath(dir)
+	if err != nil {
+		return ResourceStats{}, nil, pathError(OpReadDir, dir, err)
+	}
+
+	return p.applyKustomization(ctx, base, kustomizationPath, exists, entries, skipUpdate)
+}
+
 // scanEntries returns the directories, YAML files, and recursion hints for dir.
 // The returned slices are:
 //
@@ -134,7 +436,7 @@ func (p *Processor) walkDir(ctx context.Context, dir, base string, parent gitign
 //	childDirs: metadata that controls how each subdirectory is traversed.
 func (p *Processor) scanEntries(
 	dir, base string,
-	matcher gitignore.Matcher,
+	matcher matchers,
 ) (dirEntries []string, fileEntries []string, childDirs []childDir, err error) {
 	// Get all items in the directory.
 	entries, err := os.ReadDir(dir)
@@ -144,7 +446,7 @@ func (p *Processor) scanEntries(
 
 	// Walk entries so ignores and skip patterns are applied deterministically.
 	for _, entry := range entries {
-		if isKustomization(entry.Name()) {
+		if isKustomization(entry.Name()) || entry.Name() == ConfigFileName {
 			continue
 		}
 
@@ -157,11 +459,15 @@ func (p *Processor) scanEntries(
 		fullPath := filepath.Join(dir, entry.Name())
 		rel := p.relPath(base, fullPath)
 
-		// Check .gitignore before skip patterns.
-		if matcher != nil && matcher.Ignored(fullPath, entry.IsDir()) {
+		// Check the ignore files before skip patterns.
+		if matcher.git != nil && matcher.git.Ignored(fullPath, entry.IsDir()) {
 			p.logger.Skipped("path", rel, "reason", "gitignore")
 			continue
 		}
+		if matcher.karma != nil && matcher.karma.Ignored(fullPath, entry.IsDir()) {
+			p.logger.Skipped("path", rel, "reason", "karmaignore")
+			continue
+		}
 
 		// Ask the skip matcher whether this resource should be withheld.
 		skip, mode, pattern := matchSkip(rel, entry.IsDir(), p.skipRules)
@@ -175,6 +481,12 @@ func (p *Processor) scanEntries(
 			continue
 		}
 
+		// Directories leading to included paths stay listed and walked; everything else must match.
+		if included, leads := matchInclude(rel, entry.IsDir(), p.includeRules); !included && !leads {
+			p.logger.Skipped("path", rel, "reason", "include")
+			continue
+		}
+
 		// Record directories and schedule recursive processing.
 		if entry.IsDir() {
 			dirEntries = append(dirEntries, entry.Name())
@@ -183,23 +495,68 @@ func (p *Processor) scanEntries(
 		}
 
 		// Include eligible YAML files in the resource list.
-		if isYAML(entry.Name()) {
-			fileEntries = append(fileEntries, entry.Name())
+		if !isYAML(entry.Name()) {
+			continue
+		}
+		if p.isNonResourceFile(entry.Name()) {
+			p.logger.Skipped("path", rel, "reason", "non-manifest")
+			continue
+		}
+		if p.needsTypes() {
+			metas, err := readTypeMetas(fullPath)
+			var (
+				parseErr *PathError
+				detail   []string
+			)
+			switch {
+			case errors.As(err, &parseErr):
+				// Files that do not decode, such as Helm templates, have no known type.
+				detail = []string{"error", parseErr.Err.Error()}
+			case err != nil:
+				return nil, nil, nil, err
+			}
+			if p.opts.ManifestsOnly && !isManifest(metas) {
+				p.logger.Skipped(append([]string{"path", rel, "reason", "non-manifest"}, detail...)...)
+				continue
+			}
+			if skip, kind := p.matchKind(metas); skip {
+				p.logger.Skipped(append([]string{"path", rel, "reason", "kind", "kind", kind}, detail...)...)
+				continue
+			}
 		}
+		fileEntries = append(fileEntries, entry.Name())
 	}
 
 	return dirEntries, fileEntries, childDirs, nil
 }
 
-// loadMatcher returns the matcher for dir using the parent stack.
-func (p *Processor) loadMatcher(dir string, parent gitignore.Matcher) (gitignore.Matcher, error) {
-	if !p.opts.UseGitIgnore {
-		return nil, nil
+// loadMatcher returns the matchers for dir using the parent stacks.
+func (p *Processor) loadMatcher(dir string, parent matchers) (matchers, error) {
+	var (
+		m   matchers
+		err error
+	)
+	if p.opts.UseGitIgnore {
+		if parent.git != nil {
+			m.git, err = parent.git.Child(dir)
+		} else {
+			m.git, err = gitignore.Load(dir, true)
+		}
+		if err != nil {
+			return matchers{}, err
+		}
 	}
-	if parent != nil {
-		return parent.Child(dir)
+	if p.opts.IgnoreFile != "" {
+		if parent.karma != nil {
+			m.karma, err = parent.karma.Child(dir)
+		} else {
+			m.karma, err = gitignore.LoadFile(dir, p.opts.IgnoreFile)
+		}
+		if err != nil {
+			return matchers{}, err
+		}
 	}
-	return gitignore.Load(dir, true)
+	return m, nil
 }
 
 // relPath computes a clean slash-separated relative path for logging.
@@ -234,33 +591,138 @@ func (p *Processor) pickKustomizationPath(dir string) (string, bool, error) {
 	return filepath.Join(dir, "kustomization.yaml"), false, nil
 }
 
-// updateKustomization rewrites the resources section if it changed.
+// kustomizationUpdate describes the outcome of syncing a single kustomization.
+type kustomizationUpdate struct {
+	changed bool          // True when the resources block differs from the file.
+	existed bool          // True when the file existed before the update.
+	order   []string      // Resources as found in the file.
+	final   []string      // Resources after merging.
+	compOld []string      // Components as found in the file.
+	compNew []string      // Components after merging.
+	stats   ResourceStats // Counters describing the change.
+	before  []byte        // File content before the update.
+	after   []byte        // Rendered file content.
+
+	referenced []string // Subdirectories left out because other fields reference all their files.
+}
+
+// updateKustomization rewrites the resources and components sections if they changed.
+// Log paths are relative to base.
 func (p *Processor) updateKustomization(
-	path string,
+	ctx context.Context,
+	base, path string,
 	exists bool,
-	dirEntries, fileEntries []string,
-) (updated bool, order, final []string, stats ResourceStats, err error) {
+	entries listing,
+) (kustomizationUpdate, error) {
 	// Load or initialize the target YAML document.
-	root, seq, order, nodes, err := p.loadKustomization(path, exists)
+	doc, err := p.loadKustomization(path, exists)
+	if err != nil {
+		return kustomizationUpdate{}, pathError(OpParse, path, err)
+	}
+
+	// Files already used as patches, generator sources, etc. must not become resources,
+	// and neither must directories holding nothing else.
+	dir := filepath.Dir(path)
+	files := p.withoutReferences(base, dir, entries.files, doc.references)
+	dirs, referenced, err := p.withoutReferencedDirs(base, dir, entries.dirs, entries.matcher, doc.references)
 	if err != nil {
-		return false, nil, nil, ResourceStats{}, err
+		return kustomizationUpdate{}, pathError(OpReadDir, dir, err)
+	}
+
+	// Directories that only lead to included paths keep everything they list.
+	var keptDirs, keptComponents []string
+	if entries.keepExisting {
+		keptDirs, files = keepExistingEntries(dir, doc.order, dirs, files, doc.references)
+		keptComponents, _ = keepExistingEntries(dir, doc.componentOrder, entries.components, nil, doc.references)
+	}
+
+	// Build the canonical resource and component order.
+	final := p.mergeResources(dir, doc.order, dirs, files, keptDirs)
+	components := p.mergeComponents(dir, doc.componentOrder, entries.components, keptComponents)
+	upd, err := p.rewriteKustomization(ctx, path, exists, doc, final, components)
+	upd.referenced = referenced
+	return upd, err
+}
+
+// rewriteKustomization replaces the managed sequences of doc and writes the file when they changed.
+func (p *Processor) rewriteKustomization(
+	ctx context.Context,
+	path string,
+	exists bool,
+	doc *kustomizationDoc,
+	final, components []string,
+) (kustomizationUpdate, error) {
+	upd := kustomizationUpdate{
+		existed: exists,
+		order:   doc.order,
+		final:   final,
+		compOld: doc.componentOrder,
+		compNew: components,
+		before:  doc.raw,
+	}
+	resourcesChanged := !slices.Equal(final, doc.order)
+	componentsChanged := !slices.Equal(components, doc.componentOrder)
+	if !resourcesChanged && !componentsChanged {
+		return upd, nil
+	}
+
+	// Count the changes across both sequences.
+	for _, pair := range [][2][]string{{doc.order, final}, {doc.componentOrder, components}} {
+		added, removed := diffEntries(pair[0], pair[1])
+		upd.stats.Added += len(added)
+		upd.stats.Removed += len(removed)
+		if orderChanged(pair[0], pair[1]) {
+			upd.stats.Reordered = 1
+		}
 	}
 
-	// Build the canonical resource order.
-	final = p.mergeResources(order, dirEntries, fileEntries)
-	if slices.Equal(final, order) {
-		return false, order, final, ResourceStats{}, nil
+	if resourcesChanged {
+		fillSeq(doc.resources, final, doc.nodes)
 	}
-	added, removed := diffEntries(order, final)
-	stats.Added = len(added)
-	stats.Removed = len(removed)
-	if orderChanged(order, final) {
-		stats.Reordered = 1
+	if componentsChanged {
+		// Only create the components block once there is something to list.
+		if doc.components == nil {
+			doc.components, _, _ = ensureSeq(doc.root, "components")
+		}
+		fillSeq(doc.components, components, doc.componentNodes)
 	}
 
-	// Build scalar nodes for each entry.
-	content := make([]*yaml.Node, 0, len(final))
-	for _, val := range final {
+	// Splice only the changed blocks into the original text; re-encode when that is not possible.
+	changed := make(map[string][]string, 2)
+	if resourcesChanged {
+		changed["resources"] = final
+	}
+	if componentsChanged {
+		changed["components"] = components
+	}
+	var err error
+	upd.after, err = renderKustomization(doc, changed)
+	if err != nil {
+		return kustomizationUpdate{}, pathError(OpEncode, path, err)
+	}
+	upd.changed = true
+
+	// Read-only modes only report the change, so never touch the disk.
+	if p.readOnly() {
+		return upd, nil
+	}
+
+	// Never start a write once the run was cancelled; files written so far stay complete.
+	if err := ctx.Err(); err != nil {
+		return kustomizationUpdate{}, fmt.Errorf("abort before writing %s: %w", path, err)
+	}
+	if err := writeKustomization(path, upd.after); err != nil {
+		return kustomizationUpdate{}, pathError(OpWrite, path, err)
+	}
+	p.state.recordWritten(path)
+
+	return upd, nil
+}
+
+// fillSeq replaces the sequence content with scalar nodes for values, reusing existing nodes.
+func fillSeq(seq *yaml.Node, values []string, nodes map[string]*yaml.Node) {
+	content := make([]*yaml.Node, 0, len(values))
+	for _, val := range values {
 		// Reuse existing nodes whenever possible.
 		if node, ok := nodes[val]; ok {
 			content = append(content, node)
@@ -273,36 +735,41 @@ func (p *Processor) updateKustomization(
 		})
 	}
 	seq.Content = content
+}
+
+// renderKustomization returns the new file content, keeping everything but the changed blocks byte for byte
+// when the original layout allows it. Preserved trailing documents are appended unchanged.
+func renderKustomization(doc *kustomizationDoc, changed map[string][]string) ([]byte, error) {
+	data, ok := doc.layout.splice(changed)
+	if !ok {
+		var err error
+		if data, err = encodeKustomization(doc.root); err != nil {
+			return nil, err
+		}
+	}
+	return append(data, doc.trailer...), nil
+}
 
-	// Encode through a buffer so the document marker can be added.
+// encodeKustomization renders the document with the canonical document start.
+func encodeKustomization(root *yaml.Node) ([]byte, error) {
+	// Always prepend the canonical document start.
 	var buf bytes.Buffer
+	buf.WriteString("---\n")
+
 	enc := yaml.NewEncoder(&buf)
 	enc.SetIndent(2)
 	if err := enc.Encode(root); err != nil {
-		return false, nil, nil, ResourceStats{}, fmt.Errorf("encode: %w", err)
+		return nil, err
 	}
 	if err := enc.Close(); err != nil {
-		return false, nil, nil, ResourceStats{}, fmt.Errorf("close encoder: %w", err)
-	}
-
-	// Create or truncate the target file before writing the encoded YAML.
-	file, err := os.Create(path)
-	if err != nil {
-		return false, nil, nil, ResourceStats{}, fmt.Errorf("create %s: %w", path, err)
-	}
-	defer file.Close() // nolint:errcheck
-
-	// Always prepend the canonical document start.
-	if _, err := file.WriteString("---\n"); err != nil {
-		return false, nil, nil, ResourceStats{}, fmt.Errorf("write prefix: %w", err)
-	}
-
-	// Write the encoded document after the header.
-	if _, err := file.Write(buf.Bytes()); err != nil {
-		return false, nil, nil, ResourceStats{}, fmt.Errorf("write content: %w", err)
+		return nil, fmt.Errorf("close encoder: %w", err)
 	}
+	return buf.Bytes(), nil
+}
 
-	return true, order, final, stats, nil
+// readOnly reports whether changes must only be reported instead of written.
+func (p *Processor) readOnly() bool {
+	return p.opts.Check || p.opts.DryRun || p.opts.Plan
 }
 
 // diffEntries returns the added and removed elements when comparing two resource lists.
@@ -369,37 +836,42 @@ func orderChanged(old, new []string) bool {
 }
 
 // applyKustomization decides whether to rewrite a kustomization based on skip flags.
+// It also returns the subdirectories left out because other fields reference their files.
 func (p *Processor) applyKustomization(
-	dir, path string,
+	ctx context.Context,
+	base, path string,
 	exists bool,
-	dirEntries, fileEntries []string,
+	entries listing,
 	skipUpdate bool,
-) (ResourceStats, error) {
+) (ResourceStats, []string, error) {
 	if skipUpdate {
-		p.logger.Trace("skip-update", "dir", dir)
-		return ResourceStats{}, nil
+		p.logger.Trace("skip-update", "dir", filepath.Dir(path))
+		return ResourceStats{}, nil, nil
 	}
 
 	// Rewrite the file unless skipUpdate was requested.
-	updatedDir, order, final, stats, err := p.updateKustomization(path, exists, dirEntries, fileEntries)
+	upd, err := p.updateKustomization(ctx, base, path, exists, entries)
 	if err != nil {
-		return ResourceStats{}, err
+		return ResourceStats{}, nil, err
 	}
 	// Log whether the file was updated.
-	if updatedDir {
-		stats = p.logUpdate(path, stats, order, final)
+	if upd.changed {
+		if p.opts.Plan {
+			p.plan.record(path, upd)
+		}
+		stats := p.logUpdate(path, upd)
 		stats.Updated = 1
-		return stats, nil
+		return stats, upd.referenced, nil
 	}
 
-	stats.NoOp = 1
 	p.logger.NoOp(path)
 
-	return stats, nil
+	return ResourceStats{NoOp: 1}, upd.referenced, nil
 }
 
 // logUpdate logs the update statistics and diffs.
-func (p *Processor) logUpdate(path string, stats ResourceStats, order, final []string) ResourceStats {
+func (p *Processor) logUpdate(path string, upd kustomizationUpdate) ResourceStats {
+	stats := upd.stats
 	var changeParts []string
 	if stats.Reordered > 0 {
 		changeParts = append(changeParts, "order")
@@ -410,32 +882,92 @@ func (p *Processor) logUpdate(path string, stats ResourceStats, order, final []s
 	if stats.Removed > 0 {
 		changeParts = append(changeParts, "removed")
 	}
+	// Read-only modes report drift instead of an update.
+	report := p.logger.Updated
+	if p.readOnly() {
+		report = p.logger.Drift
+	}
 	if len(changeParts) > 0 {
-		p.logger.Updated(path, "change", strings.Join(changeParts, "+"))
+		report(path, "change", strings.Join(changeParts, "+"))
 	} else {
-		p.logger.Updated(path)
+		report(path)
+	}
+	p.logger.ResourceDiff(path, "resources", upd.order, upd.final)
+	p.logger.ResourceDiff(path, "components", upd.compOld, upd.compNew)
+	if p.opts.Diff {
+		p.logger.FileDiff(path, diff.Unified(diffLabel("a", path, upd.existed), diffLabel("b", path, true), upd.before, upd.after))
 	}
-	p.logger.ResourceDiff(order, final)
 	return stats
 }
 
+// diffLabel builds the file header for a unified diff, using /dev/null for missing files.
+func diffLabel(prefix, path string, exists bool) string {
+	if !exists {
+		return "/dev/null"
+	}
+	if filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	return prefix + "/" + filepath.ToSlash(path)
+}
+
+// kustomizationDoc holds a parsed kustomization together with the bytes it was read from.
+type kustomizationDoc struct {
+	raw       []byte                // Original file content; empty for new files.
+	root      *yaml.Node            // Document node.
+	resources *yaml.Node            // Resources sequence node.
+	order     []string              // Existing resources in file order.
+	nodes     map[string]*yaml.Node // Existing resource nodes by value.
+
+	components     *yaml.Node            // Components sequence node; nil when the block is missing.
+	componentOrder []string              // Existing components in file order.
+	componentNodes map[string]*yaml.Node // Existing component nodes by value.
+
+	references map[string]string // Local paths referenced by other fields, mapped to the field.
+	layout     *sourceLayout     // Positions in raw used to splice changes; nil forces a re-encode.
+	trailer    []byte            // Documents after the first, appended verbatim on rewrite.
+}
+
 // loadKustomization reads or initializes the YAML document.
-func (p *Processor) loadKustomization(
-	path string,
-	exists bool,
-) (root *yaml.Node, seq *yaml.Node, order []string, nodes map[string]*yaml.Node, err error) {
-	root = &yaml.Node{}
+func (p *Processor) loadKustomization(path string, exists bool) (*kustomizationDoc, error) {
+	if !exists {
+		return p.parseKustomization(path, nil, false)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseKustomization(path, data, true)
+}
+
+// parseKustomization builds the document for the kustomization at path from data, read from disk when exists is set.
+func (p *Processor) parseKustomization(path string, data []byte, exists bool) (*kustomizationDoc, error) {
+	doc := &kustomizationDoc{root: &yaml.Node{}}
+	root := doc.root
 
 	if exists {
 		// Read the existing node tree to preserve comments.
-		var data []byte
-		data, err = os.ReadFile(path)
-		if err != nil {
-			return nil, nil, nil, nil, err
+		doc.raw = data
+
+		// Only the first document is managed; the others are refused or carried along untouched.
+		first, rest := splitDocuments(data)
+		if hasDocuments(rest) {
+			if !p.opts.PreserveDocuments {
+				return nil, multiDocumentError(first)
+			}
+			data, doc.trailer = first, rest
 		}
-		err = yaml.Unmarshal(data, root)
-		if err != nil {
-			return nil, nil, nil, nil, err
+		if err := decodeDocument(data, root); err != nil {
+			return nil, err
+		}
+
+		// Strict mode never rewrites what it would have to coerce below; a scalar or sequence is never replaced.
+		if p.opts.Strict {
+			if diags := checkStructure(path, root); len(diags) > 0 {
+				return nil, errors.Join(diags...)
+			}
+		} else if err := checkTopLevel(path, root); err != nil {
+			return nil, err
 		}
 	}
 
@@ -449,38 +981,61 @@ func (p *Processor) loadKustomization(
 		root.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
 	}
 
-	// Normalize the first child to a mapping node.
-	if root.Content[0].Kind != yaml.MappingNode {
-		root.Content[0].Kind = yaml.MappingNode
+	// Replace a null document with an empty mapping that keeps its comments.
+	spliceable := root.Content[0].Kind == yaml.MappingNode
+	if !spliceable {
+		old := root.Content[0]
+		root.Content[0] = &yaml.Node{
+			Kind:        yaml.MappingNode,
+			HeadComment: old.HeadComment,
+			LineComment: old.LineComment,
+			FootComment: old.FootComment,
+		}
+	}
+
+	// Remember the original first key and which header nodes were added to splice them later.
+	mapNode := root.Content[0]
+	var firstKey *yaml.Node
+	if len(mapNode.Content) > 0 {
+		firstKey = mapNode.Content[0]
+	}
+	before := len(mapNode.Content)
+	ensureHeader(mapNode)
+	if spliceable {
+		doc.layout = newSourceLayout(doc.raw[:len(doc.raw)-len(doc.trailer)], mapNode, firstKey, mapNode.Content[:len(mapNode.Content)-before])
+	}
+
+	var err error
+	doc.resources, doc.order, doc.nodes, err = ensureResourcesSeq(root)
+	if err != nil {
+		return nil, err
 	}
 
-	ensureHeader(root.Content[0])
+	doc.references = collectReferences(root.Content[0])
 
-	seq, order, nodes, err = ensureResourcesSeq(root)
-	return root, seq, order, nodes, err
+	// Components are only managed once the block exists or a Component directory shows up.
+	if seq := mappingValue(root.Content[0], "components"); seq != nil {
+		doc.components = seq
+		doc.componentNodes, doc.componentOrder = collectExistingResources(seq)
+	}
+	return doc, nil
 }
 
 // ensureResourcesSeq guarantees the resources block exists.
 func ensureResourcesSeq(root *yaml.Node) (seq *yaml.Node, order []string, nodes map[string]*yaml.Node, err error) {
-	mapNode := root.Content[0]
-	for i := 0; i < len(mapNode.Content); i += 2 {
-		// Iterate key/value pairs, keeping resources when found.
-		if i+1 >= len(mapNode.Content) {
-			break
-		}
+	seq, order, nodes = ensureSeq(root, "resources")
+	return seq, order, nodes, err
+}
 
-		key := mapNode.Content[i]
-		if key.Value == "resources" {
-			// Stop at the first resources entry so it can be reused.
-			seq = mapNode.Content[i+1]
-			break
-		}
-	}
+// ensureSeq guarantees the sequence block named key exists and indexes its entries.
+func ensureSeq(root *yaml.Node, key string) (seq *yaml.Node, order []string, nodes map[string]*yaml.Node) {
+	mapNode := root.Content[0]
+	seq = mappingValue(mapNode, key)
 
-	// Create a resources sequence if none exists yet.
+	// Create the sequence if none exists yet.
 	if seq == nil {
 		seq = &yaml.Node{Kind: yaml.SequenceNode}
-		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: "resources", Tag: "!!str"}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key, Tag: "!!str"}
 		mapNode.Content = append(mapNode.Content, keyNode, seq)
 	}
 
@@ -490,24 +1045,65 @@ func ensureResourcesSeq(root *yaml.Node) (seq *yaml.Node, order []string, nodes
 	}
 
 	nodes, order = collectExistingResources(seq)
-	return seq, order, nodes, err
+	return seq, order, nodes
 }
 
+// mappingValue returns the value node stored under key, or nil when the key is missing.
+func mappingValue(mapNode *yaml.Node, key string) *yaml.Node {
+	if mapNode == nil || mapNode.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(mapNode.Content); i += 2 {
+		// Iterate key/value pairs, keeping the first match.
+		if i+1 >= len(mapNode.Content) {
+			break
+		}
+		if mapNode.Content[i].Value == key {
+			return mapNode.Content[i+1]
+		}
+	}
+	return nil
+}
+
+const (
+	kustomizationAPIVersion = "kustomize.config.k8s.io/v1beta1"
+	componentAPIVersion     = "kustomize.config.k8s.io/v1alpha1"
+	kindKustomization       = "Kustomization"
+	kindComponent           = "Component"
+)
+
 // ensureHeader injects the canonical header keys at the top when missing.
+// A document that declares its kind but no apiVersion gets the apiVersion matching that kind.
 func ensureHeader(mapNode *yaml.Node) {
-	// Detect if a header already exists; if so, leave it untouched.
-	for i := 0; i < len(mapNode.Content); i += 2 {
-		key := mapNode.Content[i].Value
-		if key == "apiVersion" || key == "kind" {
-			return
+	hasAPIVersion := false
+	kind := ""
+	for i := 0; i+1 < len(mapNode.Content); i += 2 {
+		switch mapNode.Content[i].Value {
+		case "apiVersion":
+			hasAPIVersion = true
+		case "kind":
+			kind = mapNode.Content[i+1].Value
 		}
 	}
 
+	// Detect if a header already exists; if so, leave it untouched.
+	if hasAPIVersion {
+		return
+	}
+
+	apiVersion := kustomizationAPIVersion
+	if kind == kindComponent {
+		apiVersion = componentAPIVersion
+	}
 	header := []*yaml.Node{
 		{Kind: yaml.ScalarNode, Value: "apiVersion", Tag: "!!str"},
-		{Kind: yaml.ScalarNode, Value: "kustomize.config.k8s.io/v1beta1", Tag: "!!str"},
-		{Kind: yaml.ScalarNode, Value: "kind", Tag: "!!str"},
-		{Kind: yaml.ScalarNode, Value: "Kustomization", Tag: "!!str"},
+		{Kind: yaml.ScalarNode, Value: apiVersion, Tag: "!!str"},
+	}
+	if kind == "" {
+		header = append(header,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "kind", Tag: "!!str"},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: kindKustomization, Tag: "!!str"},
+		)
 	}
 
 	// Prepend the header nodes so the header keys appear first in the document.
@@ -537,9 +1133,12 @@ func collectExistingResources(seq *yaml.Node) (nodes map[string]*yaml.Node, orde
 }
 
 // mergeResources produces the canonical ordering for resources.
-func (p *Processor) mergeResources(existing []string, dirEntries, fileEntries []string) []string {
+// Existing entries in dir that point outside of it are kept as the external group.
+// Kept directories join the directory group as written, without prefix or suffix changes.
+func (p *Processor) mergeResources(dir string, existing []string, dirEntries, fileEntries, keptDirs []string) []string {
 	dirs := p.ensureDirPrefix(dirEntries)
 	dirs = p.ensureDirSuffix(dirs)
+	dirs = append(dirs, keptDirs...)
 	files := append([]string(nil), fileEntries...) // Create a copy of the existing resources.
 
 	sort.Strings(dirs)
@@ -554,13 +1153,18 @@ func (p *Processor) mergeResources(existing []string, dirEntries, fileEntries []
 	}
 	sort.Strings(remote)
 
+	// Preserve out-of-tree local references in their existing order.
+	external := p.externalReferences(dir, existing)
+
 	order := normalizeResourceOrder(p.opts.ResourceOrder)
 
-	final := make([]string, 0, len(remote)+len(dirs)+len(files))
+	final := make([]string, 0, len(remote)+len(external)+len(dirs)+len(files))
 	for _, group := range order {
 		switch group {
 		case resourceGroupRemote:
 			final = append(final, remote...)
+		case resourceGroupExternal:
+			final = append(final, external...)
 		case resourceGroupDirs:
 			final = append(final, dirs...)
 		case resourceGroupFiles:
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -47,6 +48,71 @@ func TestProcessorProcess(t *testing.T) {
 		assert.Equal(t, 0, stats.Updated)
 		assert.Equal(t, 1, stats.NoOp)
 	})
+
+	t.Run("check mode reports drift without writing", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		proc := New(Options{Check: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Updated)
+		assert.Equal(t, 1, stats.Added)
+
+		_, err = os.Stat(filepath.Join(temp, "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("negated skip keeps one entry of a skipped directory", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		for _, dir := range []string{"legacy/old", "legacy/keep"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(temp, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, dir, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		}
+		proc := New(Options{Skip: []string{"legacy/*", "!legacy/keep"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "legacy", "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- keep")
+		assert.NotContains(t, string(data), "old")
+		_, err = os.Stat(filepath.Join(temp, "legacy", "old", "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("records written kustomizations", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "app"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app", "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(temp, "app", "kustomization.yaml"),
+			filepath.Join(temp, "kustomization.yaml"),
+		}, proc.Written())
+	})
+
+	t.Run("cancelled context writes nothing", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := proc.Process(ctx, temp)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, proc.Written())
+		_, err = os.Stat(filepath.Join(temp, "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
 }
 
 func TestResourceStatsAdd(t *testing.T) {
@@ -84,7 +150,7 @@ func TestScanEntries(t *testing.T) {
 			IncludeDot: false,
 		}, logger)
 
-		dirEntries, fileEntries, childDirs, err := proc.scanEntries(temp, temp, nil)
+		dirEntries, fileEntries, childDirs, err := proc.scanEntries(temp, temp, matchers{})
 		require.NoError(t, err)
 		assert.Contains(t, dirEntries, "normal")
 		assert.Contains(t, dirEntries, "skipdir")
@@ -98,15 +164,41 @@ func TestScanEntries(t *testing.T) {
 	})
 }
 
+func TestScanEntriesIgnoreFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("skips entries listed in the ignore file", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, DefaultIgnoreFile), []byte("values.yaml\nfixtures/\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "values.yaml"), []byte("x: 1\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		require.NoError(t, os.Mkdir(filepath.Join(temp, "fixtures"), 0o755))
+
+		out := &bytes.Buffer{}
+		proc := New(Options{IgnoreFile: DefaultIgnoreFile}, logging.New(out, io.Discard, logging.LevelDebug))
+		matcher, err := proc.loadMatcher(temp, matchers{})
+		require.NoError(t, err)
+
+		dirEntries, fileEntries, childDirs, err := proc.scanEntries(temp, temp, matcher)
+		require.NoError(t, err)
+		assert.Empty(t, dirEntries)
+		assert.Empty(t, childDirs)
+		assert.Equal(t, []string{"app.yaml"}, fileEntries)
+		assert.Contains(t, out.String(), "path=values.yaml reason=karmaignore")
+	})
+}
+
 func TestProcessorLoadMatcher(t *testing.T) {
 	t.Parallel()
 
 	t.Run("returns nil when disabled", func(t *testing.T) {
 		t.Parallel()
 		proc := New(Options{UseGitIgnore: false}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
-		matcher, err := proc.loadMatcher(t.TempDir(), nil)
+		matcher, err := proc.loadMatcher(t.TempDir(), matchers{})
 		require.NoError(t, err)
-		assert.Nil(t, matcher)
+		assert.Nil(t, matcher.git)
+		assert.Nil(t, matcher.karma)
 	})
 
 	t.Run("loads and respects gitignore", func(t *testing.T) {
@@ -115,10 +207,23 @@ func TestProcessorLoadMatcher(t *testing.T) {
 		require.NoError(t, os.WriteFile(filepath.Join(temp, ".gitignore"), []byte("secret.txt\n"), 0o644))
 		proc := New(Options{UseGitIgnore: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
 
-		matcher, err := proc.loadMatcher(temp, nil)
+		matcher, err := proc.loadMatcher(temp, matchers{})
+		require.NoError(t, err)
+		require.NotNil(t, matcher.git)
+		assert.True(t, matcher.git.Ignored(filepath.Join(temp, "secret.txt"), false))
+	})
+
+	t.Run("loads the ignore file without gitignore", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(temp, DefaultIgnoreFile), []byte("values.yaml\n"), 0o644))
+		proc := New(Options{IgnoreFile: DefaultIgnoreFile}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		matcher, err := proc.loadMatcher(temp, matchers{})
 		require.NoError(t, err)
-		require.NotNil(t, matcher)
-		assert.True(t, matcher.Ignored(filepath.Join(temp, "secret.txt"), false))
+		assert.Nil(t, matcher.git)
+		require.NotNil(t, matcher.karma)
+		assert.True(t, matcher.karma.Ignored(filepath.Join(temp, "values.yaml"), false))
 	})
 }
 
@@ -204,21 +309,62 @@ func TestProcessorUpdateKustomization(t *testing.T) {
 		}
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(opts, logger)
-		updated, order, final, stats, err := proc.updateKustomization(path, true, []string{"added"}, []string{"alpha.yaml"})
+		upd, err := proc.updateKustomization(context.Background(), filepath.Dir(path), path, true, listing{dirs: []string{"added"}, files: []string{"alpha.yaml"}})
 		require.NoError(t, err)
-		assert.True(t, updated)
-		assert.Equal(t, 0, stats.Reordered)
-		assert.Equal(t, []string{"existing"}, order)
-		t.Logf("final resources: %v", final)
-		assert.Contains(t, final, "./added/")
-		assert.Contains(t, final, "alpha.yaml")
-		assert.Greater(t, stats.Added, 0)
+		assert.True(t, upd.changed)
+		assert.Equal(t, 0, upd.stats.Reordered)
+		assert.Equal(t, []string{"existing"}, upd.order)
+		t.Logf("final resources: %v", upd.final)
+		assert.Contains(t, upd.final, "./added/")
+		assert.Contains(t, upd.final, "alpha.yaml")
+		assert.Greater(t, upd.stats.Added, 0)
+		assert.Equal(t, "---\nresources:\n  - existing\n", string(upd.before))
 
 		data, err := os.ReadFile(path)
 		require.NoError(t, err)
 		assert.Contains(t, string(data), "./added/")
 		assert.Contains(t, string(data), "apiVersion")
 		assert.Contains(t, string(data), "kind")
+		assert.Equal(t, string(upd.after), string(data))
+	})
+
+	t.Run("dry run renders without writing", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		original := "---\nresources:\n  - existing\n"
+		require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
+		proc := New(Options{DryRun: true}, logger)
+
+		upd, err := proc.updateKustomization(context.Background(), filepath.Dir(path), path, true, listing{files: []string{"alpha.yaml"}})
+		require.NoError(t, err)
+		assert.True(t, upd.changed)
+		assert.Contains(t, string(upd.after), "alpha.yaml")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, original, string(data))
+	})
+
+	t.Run("refuses to write after cancellation", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		original := "---\nresources:\n  - existing\n"
+		require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := proc.updateKustomization(ctx, filepath.Dir(path), path, true, listing{files: []string{"alpha.yaml"}})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.EqualError(t, err, "abort before writing "+path+": context canceled")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, original, string(data))
+		assert.Empty(t, proc.Written())
 	})
 
 	t.Run("returns false when unchanged", func(t *testing.T) {
@@ -229,17 +375,17 @@ func TestProcessorUpdateKustomization(t *testing.T) {
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(Options{}, logger)
 
-		_, _, _, _, err := proc.updateKustomization(path, true, []string{"exist"}, nil)
+		_, err := proc.updateKustomization(context.Background(), filepath.Dir(path), path, true, listing{dirs: []string{"exist"}})
 		require.NoError(t, err)
 
-		updated, order, final, stats, err := proc.updateKustomization(path, true, []string{"exist"}, nil)
+		upd, err := proc.updateKustomization(context.Background(), filepath.Dir(path), path, true, listing{dirs: []string{"exist"}})
 		require.NoError(t, err)
-		assert.False(t, updated)
-		assert.Equal(t, 0, stats.Reordered)
-		assert.Equal(t, []string{"exist"}, order)
-		assert.Equal(t, []string{"exist"}, final)
-		assert.Equal(t, 0, stats.Added)
-		assert.Equal(t, 0, stats.Removed)
+		assert.False(t, upd.changed)
+		assert.Equal(t, 0, upd.stats.Reordered)
+		assert.Equal(t, []string{"exist"}, upd.order)
+		assert.Equal(t, []string{"exist"}, upd.final)
+		assert.Equal(t, 0, upd.stats.Added)
+		assert.Equal(t, 0, upd.stats.Removed)
 	})
 }
 
@@ -250,7 +396,7 @@ func TestProcessorApplyKustomization(t *testing.T) {
 		t.Parallel()
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(Options{}, logger)
-		stats, err := proc.applyKustomization("", "", true, nil, nil, true)
+		stats, _, err := proc.applyKustomization(context.Background(), "", "", true, listing{}, true)
 		require.NoError(t, err)
 		assert.Equal(t, 0, stats.Updated)
 		assert.Equal(t, 0, stats.NoOp)
@@ -264,11 +410,42 @@ func TestProcessorApplyKustomization(t *testing.T) {
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(opts, logger)
 
-		stats, err := proc.applyKustomization(temp, path, false, []string{"dir"}, []string{"file.yaml"}, false)
+		stats, _, err := proc.applyKustomization(context.Background(), temp, path, false, listing{dirs: []string{"dir"}, files: []string{"file.yaml"}}, false)
 		require.NoError(t, err)
 		assert.Equal(t, 1, stats.Updated)
 		assert.Equal(t, 0, stats.NoOp)
 	})
+
+	t.Run("prints unified diff when requested", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		var out bytes.Buffer
+		logger := logging.New(&out, io.Discard, logging.LevelInfo)
+		proc := New(Options{Diff: true, DryRun: true}, logger)
+
+		stats, _, err := proc.applyKustomization(context.Background(), temp, path, false, listing{files: []string{"file.yaml"}}, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Updated)
+		assert.Contains(t, out.String(), "--- /dev/null\n")
+		assert.Contains(t, out.String(), "+++ "+filepath.ToSlash(path)+"\n")
+		assert.Contains(t, out.String(), "+  - file.yaml")
+		assert.Contains(t, out.String(), "[DRIFT")
+	})
+}
+
+func TestDiffLabel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "/dev/null", diffLabel("a", "apps/kustomization.yaml", false))
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "b/apps/kustomization.yaml", diffLabel("b", "apps/kustomization.yaml", true))
+	})
 }
 
 func TestProcessorLoadKustomization(t *testing.T) {
@@ -282,12 +459,13 @@ func TestProcessorLoadKustomization(t *testing.T) {
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(Options{}, logger)
 
-		root, seq, order, nodes, err := proc.loadKustomization(path, true)
+		doc, err := proc.loadKustomization(path, true)
 		require.NoError(t, err)
-		require.NotNil(t, root)
-		require.NotNil(t, seq)
-		require.NotNil(t, nodes)
-		assert.Contains(t, order, "kept")
+		require.NotNil(t, doc.root)
+		require.NotNil(t, doc.resources)
+		require.NotNil(t, doc.nodes)
+		assert.Contains(t, doc.order, "kept")
+		assert.Equal(t, "---\nresources:\n  - kept\n", string(doc.raw))
 	})
 
 	t.Run("initializes missing document", func(t *testing.T) {
@@ -297,12 +475,13 @@ func TestProcessorLoadKustomization(t *testing.T) {
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(Options{}, logger)
 
-		root, seq, order, nodes, err := proc.loadKustomization(path, false)
+		doc, err := proc.loadKustomization(path, false)
 		require.NoError(t, err)
-		require.NotNil(t, root)
-		require.NotNil(t, seq)
-		assert.Empty(t, order)
-		assert.Empty(t, nodes)
+		require.NotNil(t, doc.root)
+		require.NotNil(t, doc.resources)
+		assert.Empty(t, doc.order)
+		assert.Empty(t, doc.nodes)
+		assert.Empty(t, doc.raw)
 	})
 }
 
@@ -343,6 +522,40 @@ func TestEnsureResourcesSeq(t *testing.T) {
 	})
 }
 
+func TestEnsureHeader(t *testing.T) {
+	t.Parallel()
+
+	scalar := func(v string) *yaml.Node { return &yaml.Node{Kind: yaml.ScalarNode, Value: v} }
+	values := func(n *yaml.Node) []string {
+		out := make([]string, 0, len(n.Content))
+		for _, c := range n.Content {
+			out = append(out, c.Value)
+		}
+		return out
+	}
+
+	t.Run("adds kustomization header", func(t *testing.T) {
+		t.Parallel()
+		mapNode := &yaml.Node{Kind: yaml.MappingNode}
+		ensureHeader(mapNode)
+		assert.Equal(t, []string{"apiVersion", "kustomize.config.k8s.io/v1beta1", "kind", "Kustomization"}, values(mapNode))
+	})
+
+	t.Run("adds component api version", func(t *testing.T) {
+		t.Parallel()
+		mapNode := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalar("kind"), scalar("Component")}}
+		ensureHeader(mapNode)
+		assert.Equal(t, []string{"apiVersion", "kustomize.config.k8s.io/v1alpha1", "kind", "Component"}, values(mapNode))
+	})
+
+	t.Run("keeps existing header", func(t *testing.T) {
+		t.Parallel()
+		mapNode := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalar("apiVersion"), scalar("v1")}}
+		ensureHeader(mapNode)
+		assert.Equal(t, []string{"apiVersion", "v1"}, values(mapNode))
+	})
+}
+
 func TestCollectExistingResources(t *testing.T) {
 	t.Parallel()
 
@@ -379,7 +592,7 @@ func TestMergeResourcesOrders(t *testing.T) {
 		}
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(opts, logger)
-		final := proc.mergeResources([]string{"https://example.com"}, []string{"b", "a"}, []string{"z", "y"})
+		final := proc.mergeResources("", []string{"https://example.com"}, []string{"b", "a"}, []string{"z", "y"}, nil)
 		require.Equal(t, []string{"https://example.com", "./a/", "./b/", "y", "z"}, final)
 	})
 
@@ -393,9 +606,31 @@ func TestMergeResourcesOrders(t *testing.T) {
 		}
 		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
 		proc := New(opts, logger)
-		final := proc.mergeResources([]string{"https://example.com", "https://stable.com"}, []string{"b", "a"}, []string{"x"})
+		final := proc.mergeResources("", []string{"https://example.com", "https://stable.com"}, []string{"b", "a"}, []string{"x"}, nil)
 		require.Equal(t, []string{"https://example.com", "https://stable.com", "x", "./a/", "./b/"}, final)
 	})
+
+	t.Run("keeps non-http remotes", func(t *testing.T) {
+		t.Parallel()
+		opts := Options{ResourceOrder: []string{"remote", "files"}}
+		logger := logging.New(io.Discard, io.Discard, logging.LevelInfo)
+		proc := New(opts, logger)
+		existing := []string{
+			"stale.yaml",
+			"oci://ghcr.io/org/manifests:1.0.0",
+			"github.com/org/repo//deploy?ref=v1.2",
+			"git@github.com:org/repo.git//x",
+			"ssh://git@example.com/org/repo.git",
+		}
+		final := proc.mergeResources("", existing, nil, []string{"app.yaml"}, nil)
+		require.Equal(t, []string{
+			"git@github.com:org/repo.git//x",
+			"github.com/org/repo//deploy?ref=v1.2",
+			"oci://ghcr.io/org/manifests:1.0.0",
+			"ssh://git@example.com/org/repo.git",
+			"app.yaml",
+		}, final)
+	})
 }
 
 func TestProcessorEnsureDirSuffix(t *testing.T) {
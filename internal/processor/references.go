@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// referenceShape describes where a kustomization field stores local file paths.
+type referenceShape int
+
+const (
+	refScalars   referenceShape = iota // Sequence of paths.
+	refPathKey                         // Sequence of mappings (or a single mapping) with a path key.
+	refGenerator                       // Sequence of generators with files, envs, and env keys.
+	refHelm                            // Sequence of Helm charts with values files.
+)
+
+// referenceFields lists the kustomization fields that reference local files.
+var referenceFields = map[string]referenceShape{
+	"patches":               refPathKey,
+	"patchesStrategicMerge": refScalars,
+	"patchesJson6902":       refPathKey,
+	"configMapGenerator":    refGenerator,
+	"secretGenerator":       refGenerator,
+	"helmCharts":            refHelm,
+	"transformers":          refScalars,
+	"generators":            refScalars,
+	"validators":            refScalars,
+	"replacements":          refPathKey,
+	"configurations":        refScalars,
+	"crds":                  refScalars,
+	"openapi":               refPathKey,
+}
+
+// collectReferences returns the local paths referenced by fields other than resources,
+// keyed by the cleaned path relative to the kustomization and mapped to the field name.
+func collectReferences(mapNode *yaml.Node) map[string]string {
+	refs := make(map[string]string)
+	for i := 0; i+1 < len(mapNode.Content); i += 2 {
+		field := mapNode.Content[i].Value
+		shape, ok := referenceFields[field]
+		if !ok {
+			continue
+		}
+		add := func(value string) {
+			if ref, ok := normalizeReference(value); ok {
+				refs[ref] = field
+			}
+		}
+
+		value := mapNode.Content[i+1]
+		switch shape {
+		case refScalars:
+			for _, item := range sequenceItems(value) {
+				add(scalarValue(item))
+			}
+		case refPathKey:
+			for _, item := range sequenceItems(value) {
+				add(scalarValue(mappingValue(item, "path")))
+			}
+		case refGenerator:
+			for _, item := range sequenceItems(value) {
+				for _, file := range sequenceItems(mappingValue(item, "files")) {
+					// Files may be written as key=path.
+					v := scalarValue(file)
+					if _, after, found := strings.Cut(v, "="); found {
+						v = after
+					}
+					add(v)
+				}
+				for _, env := range sequenceItems(mappingValue(item, "envs")) {
+					add(scalarValue(env))
+				}
+				add(scalarValue(mappingValue(item, "env")))
+			}
+		case refHelm:
+			for _, item := range sequenceItems(value) {
+				add(scalarValue(mappingValue(item, "valuesFile")))
+				for _, file := range sequenceItems(mappingValue(item, "additionalValuesFiles")) {
+					add(scalarValue(file))
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// normalizeReference cleans a referenced path; inline documents and remote entries are rejected.
+func normalizeReference(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.Contains(value, "\n") || isRemoteResource(value) {
+		return "", false
+	}
+	return path.Clean(value), true
+}
+
+// sequenceItems returns the entries of a sequence; a single mapping is treated as one entry.
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	switch {
+	case node == nil:
+		return nil
+	case node.Kind == yaml.SequenceNode:
+		return node.Content
+	case node.Kind == yaml.MappingNode:
+		return []*yaml.Node{node}
+	default:
+		return nil
+	}
+}
+
+// scalarValue returns the value of a scalar node or an empty string.
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// withoutReferences drops files referenced by other kustomization fields and logs why.
+func (p *Processor) withoutReferences(base, dir string, files []string, refs map[string]string) []string {
+	if len(refs) == 0 {
+		return files
+	}
+	out := make([]string, 0, len(files))
+	for _, name := range files {
+		if field, ok := refs[name]; ok {
+			p.logger.Skipped("path", p.relPath(base, filepath.Join(dir, name)), "reason", "referenced", "field", field)
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// withoutReferencedDirs drops subdirectories whose YAML files are all referenced by other kustomization fields,
+// such as a patches/ directory, and returns the dropped names so the walk does not enter them.
+func (p *Processor) withoutReferencedDirs(
+	base, dir string,
+	dirs []string,
+	matcher matchers,
+	refs map[string]string,
+) (kept, dropped []string, err error) {
+	if len(refs) == 0 {
+		return dirs, nil, nil
+	}
+	kept = make([]string, 0, len(dirs))
+	for _, name := range dirs {
+		field, err := p.referencedDir(base, dir, name, matcher, refs)
+		if err != nil {
+			return nil, nil, err
+		}
+		if field == "" {
+			kept = append(kept, name)
+			continue
+		}
+		p.logger.Skipped("path", p.relPath(base, filepath.Join(dir, name)), "reason", "referenced", "field", field)
+		dropped = append(dropped, name)
+	}
+	return kept, dropped, nil
+}
+
+// referencedDir returns the field referencing the YAML files below dir/name, or "" unless all of them are referenced.
+// A directory with a kustomization of its own or without YAML files is never considered referenced.
+// Only entries the walk would list count, and the search stops at the first file that decides.
+func (p *Processor) referencedDir(base, dir, name string, parent matchers, refs map[string]string) (string, error) {
+	field := ""
+	var visit func(sub string, parent matchers) (bool, error)
+	visit = func(sub string, parent matchers) (bool, error) {
+		full := filepath.Join(dir, filepath.FromSlash(sub))
+		matcher, err := p.loadMatcher(full, parent)
+		if err != nil {
+			return false, err
+		}
+		entries, err := os.ReadDir(full)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			if isKustomization(entry.Name()) {
+				return false, nil
+			}
+			rel := path.Join(sub, entry.Name())
+			if p.excluded(base, filepath.Join(full, entry.Name()), entry.IsDir(), matcher) {
+				continue
+			}
+			if entry.IsDir() {
+				if ok, err := visit(rel, matcher); err != nil || !ok {
+					return false, err
+				}
+				continue
+			}
+			if !isYAML(entry.Name()) || p.isNonResourceFile(entry.Name()) {
+				continue
+			}
+			ref, ok := refs[rel]
+			if !ok {
+				return false, nil
+			}
+			if field == "" {
+				field = ref
+			}
+		}
+		return true, nil
+	}
+
+	if ok, err := visit(name, parent); err != nil || !ok {
+		return "", err
+	}
+	return field, nil
+}
+
+// excluded reports whether the walk leaves the entry at full out because of the dot, ignore, skip, or include rules.
+// Unlike scanEntries it logs nothing.
+func (p *Processor) excluded(base, full string, isDir bool, matcher matchers) bool {
+	name := filepath.Base(full)
+	if name == ConfigFileName || (!p.opts.IncludeDot && strings.HasPrefix(name, ".")) {
+		return true
+	}
+	if matcher.git != nil && matcher.git.Ignored(full, isDir) {
+		return true
+	}
+	if matcher.karma != nil && matcher.karma.Ignored(full, isDir) {
+		return true
+	}
+	rel := p.relPath(base, full)
+	if skip, _, _ := matchSkip(rel, isDir, p.skipRules); skip {
+		return true
+	}
+	included, leads := matchInclude(rel, isDir, p.includeRules)
+	return !included && !leads
+}
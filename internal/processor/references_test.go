@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCollectReferences(t *testing.T) {
+	t.Parallel()
+
+	parse := func(t *testing.T, content string) *yaml.Node {
+		t.Helper()
+		var root yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(content), &root))
+		return root.Content[0]
+	}
+
+	t.Run("collects every referencing field", func(t *testing.T) {
+		t.Parallel()
+		mapNode := parse(t, `
+resources:
+  - app.yaml
+patches:
+  - path: patch.yaml
+  - patch: |-
+      - op: remove
+        path: /spec
+patchesStrategicMerge:
+  - ./strategic.yaml
+patchesJson6902:
+  - target: {kind: Deployment}
+    path: json6902.yaml
+configMapGenerator:
+  - name: cfg
+    files:
+      - config.yaml
+      - key=keyed.yaml
+    envs:
+      - vars.env
+secretGenerator:
+  - name: sec
+    env: secret.env
+helmCharts:
+  - name: chart
+    valuesFile: values.yaml
+    additionalValuesFiles:
+      - extra-values.yaml
+transformers:
+  - transformer.yaml
+generators:
+  - generator.yaml
+validators:
+  - validator.yaml
+replacements:
+  - path: replacement.yaml
+configurations:
+  - kustomizeconfig.yaml
+crds:
+  - crd.yaml
+openapi:
+  path: schema.yaml
+`)
+		refs := collectReferences(mapNode)
+		assert.Equal(t, map[string]string{
+			"patch.yaml":           "patches",
+			"strategic.yaml":       "patchesStrategicMerge",
+			"json6902.yaml":        "patchesJson6902",
+			"config.yaml":          "configMapGenerator",
+			"keyed.yaml":           "configMapGenerator",
+			"vars.env":             "configMapGenerator",
+			"secret.env":           "secretGenerator",
+			"values.yaml":          "helmCharts",
+			"extra-values.yaml":    "helmCharts",
+			"transformer.yaml":     "transformers",
+			"generator.yaml":       "generators",
+			"validator.yaml":       "validators",
+			"replacement.yaml":     "replacements",
+			"kustomizeconfig.yaml": "configurations",
+			"crd.yaml":             "crds",
+			"schema.yaml":          "openapi",
+		}, refs)
+	})
+
+	t.Run("ignores inline and remote values", func(t *testing.T) {
+		t.Parallel()
+		mapNode := parse(t, "patchesStrategicMerge:\n  - |-\n    kind: Deployment\n    metadata: {name: x}\ncrds:\n  - https://example.com/crd.yaml\n")
+		assert.Empty(t, collectReferences(mapNode))
+	})
+}
+
+func TestProcessorReferences(t *testing.T) {
+	t.Parallel()
+
+	t.Run("referenced files are not listed as resources", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		content := "resources:\n  - app.yaml\n  - patch.yaml\npatches:\n  - path: patch.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "patch.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Removed)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "resources:\n  - app.yaml\npatches:\n  - path: patch.yaml\n")
+	})
+
+	t.Run("directories holding only referenced files are not listed or walked", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		content := "resources:\n  - app.yaml\npatches:\n  - path: patches/replicas.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "patches"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "patches", "replicas.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		var out bytes.Buffer
+		proc := New(Options{}, logging.New(&out, io.Discard, logging.LevelDebug))
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.NoOp)
+		assert.Zero(t, stats.Updated)
+		assert.Contains(t, out.String(), "path=patches reason=referenced field=patches")
+
+		_, err = os.Stat(filepath.Join(temp, "patches", "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("ignored, skipped, and hidden files do not keep a referenced directory listed", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		content := "resources:\n  - app.yaml\npatches:\n  - path: patches/replicas.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: Pod\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, DefaultIgnoreFile), []byte("draft.yaml\n"), 0o644))
+		for _, name := range []string{"replicas.yaml", "draft.yaml", "wip.yaml", ".old/stale.yaml"} {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(temp, "patches", name)), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, "patches", name), []byte("kind: Pod\n"), 0o644))
+		}
+
+		proc := New(Options{IgnoreFile: DefaultIgnoreFile, Skip: []string{"patches/wip.yaml"}}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		stats, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.NoOp)
+
+		_, err = os.Stat(filepath.Join(temp, "patches", "kustomization.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("directories with unreferenced files stay listed", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		content := "resources:\n  - app\npatches:\n  - path: app/patch.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "app"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app", "patch.yaml"), []byte("kind: Pod\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app", "deploy.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(temp, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "resources:\n  - app\n")
+		_, err = os.Stat(filepath.Join(temp, "app", "kustomization.yaml"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("referenced files are logged relative to the base", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "app"), 0o755))
+		content := "patches:\n  - path: patch.yaml\n"
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app", "kustomization.yaml"), []byte(content), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app", "patch.yaml"), []byte("kind: Pod\n"), 0o644))
+
+		var out bytes.Buffer
+		proc := New(Options{}, logging.New(&out, io.Discard, logging.LevelDebug))
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "path=app/patch.yaml reason=referenced")
+	})
+}
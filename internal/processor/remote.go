@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// remoteSchemes are URL schemes kustomize loads from outside the repository.
+var remoteSchemes = []string{
+	"http://",
+	"https://",
+	"ssh://",
+	"git://",
+	"git+ssh://",
+	"git+https://",
+	"oci://",
+	"s3://",
+	"gs://",
+}
+
+// remoteGetterPrefixes are go-getter style forced getters such as git::https://...
+var remoteGetterPrefixes = []string{
+	"git::",
+	"hg::",
+	"s3::",
+	"gcs::",
+	"http::",
+	"https::",
+}
+
+// remoteHosts are well-known git hosts kustomize accepts without a scheme.
+var remoteHosts = []string{
+	"github.com/",
+	"gitlab.com/",
+	"bitbucket.org/",
+	"dev.azure.com/",
+	"ssh.dev.azure.com/",
+}
+
+// remoteQueryKeys are query parameters that only make sense on remote references.
+var remoteQueryKeys = []string{"ref", "version", "submodules", "timeout"}
+
+// scpLikeRemote matches scp-style git URLs such as git@github.com:org/repo.git.
+var scpLikeRemote = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:`)
+
+// isRemoteResource returns true for every reference form kustomize resolves remotely:
+// URLs, go-getter prefixes, scp-style git URLs, well-known hosts, host-like paths
+// with a repository separator (//), and entries carrying ?ref= or ?version= queries.
+func isRemoteResource(entry string) bool {
+	lowered := strings.ToLower(strings.TrimSpace(entry))
+	switch {
+	case lowered == "":
+		return false
+	case hasAnyPrefix(lowered, remoteSchemes), hasAnyPrefix(lowered, remoteGetterPrefixes), hasAnyPrefix(lowered, remoteHosts):
+		return true
+	case scpLikeRemote.MatchString(lowered):
+		return true
+	case hasRemoteQuery(lowered):
+		return true
+	}
+
+	// A host-like first segment marks a remote only together with a repository separator.
+	host, rest, found := strings.Cut(lowered, "/")
+	return found && looksLikeHost(host) && strings.Contains(rest, "//")
+}
+
+// hasAnyPrefix reports whether value starts with one of prefixes.
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRemoteQuery reports whether value carries a query parameter used by remote references.
+func hasRemoteQuery(value string) bool {
+	_, query, found := strings.Cut(value, "?")
+	if !found {
+		return false
+	}
+	for _, param := range strings.Split(query, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		for _, known := range remoteQueryKeys {
+			if key == known {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// looksLikeHost reports whether segment resembles a host name, optionally with a port.
+func looksLikeHost(segment string) bool {
+	name, _, _ := strings.Cut(segment, ":")
+	if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || !strings.Contains(name, ".") {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '.' || r == '-' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
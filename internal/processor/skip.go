@@ -4,6 +4,9 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync/atomic"
+
+	"github.com/gi8lino/karma/internal/glob"
 )
 
 // SkipMode enumerates how patterns should behave.
@@ -18,9 +21,22 @@ const (
 
 // skipRule represents a parsed skip pattern.
 type skipRule struct {
-	raw   string
-	mode  skipMode
-	value string
+	raw    string
+	mode   skipMode
+	value  string
+	negate bool         // Pattern started with "!" and re-includes what earlier rules skipped.
+	scope  string       // Directory (relative to the base) the pattern is relative to; empty for the base.
+	source string       // Where the pattern was declared: the --skip flag or a config file.
+	used   *atomic.Bool // Set once the rule matched a path; shared by every copy of the rule.
+}
+
+// skipSourceFlag is the source of skip patterns given on the command line.
+const skipSourceFlag = "--skip"
+
+// UnusedSkipPattern is a skip pattern that never matched any path.
+type UnusedSkipPattern struct {
+	Pattern string // Pattern as written.
+	Source  string // The --skip flag or the config file declaring it.
 }
 
 // childDir carries metadata that controls how we recurse into a directory.
@@ -31,76 +47,173 @@ type childDir struct {
 }
 
 // parseSkipRules compiles CLI patterns into skipRule entries.
+//
+// Grammar, applied to the path relative to the base (or the declaring .karma.yaml):
+//
+//	!PATTERN      re-include what earlier patterns skipped; the last matching pattern wins
+//	PATTERN/**    keep the directory listed and walk it, but never rewrite its kustomization
+//	PATTERN/*     keep the directory listed, but skip its contents and do not walk it
+//	GLOB          drop matching entries; "**" matches any number of directories
+//	NAME          drop entries with this path, or this base name when NAME has no "/"
+//
+// Globs follow .gitignore rules; patterns without "/" also match the base name.
 func parseSkipRules(patterns []string) []skipRule {
 	rules := make([]skipRule, 0, len(patterns))
 	for _, raw := range patterns {
-		rule := skipRule{raw: raw}
-		canonical := strings.TrimRight(raw, "/")
+		rule := skipRule{raw: raw, source: skipSourceFlag, used: &atomic.Bool{}}
+		pattern := raw
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		}
+		canonical := strings.TrimRight(pattern, "/")
 		switch {
 		case strings.HasSuffix(canonical, "/**"):
 			// Keep directories but skip their own kustomization.
 			rule.mode = skipModeSubtree
 			rule.value = strings.TrimSuffix(canonical, "/**")
-		case strings.HasSuffix(raw, "/*"):
+		case strings.HasSuffix(pattern, "/*"):
 			// Skip immediate children but keep the parent listed.
 			rule.mode = skipModeChildren
 			rule.value = strings.TrimSuffix(canonical, "/*")
-		case strings.ContainsAny(raw, "*?[]"):
+		case strings.ContainsAny(pattern, "*?[]"):
 			// Treat glob patterns as direct skip rules.
 			rule.mode = skipModeGlob
-			rule.value = raw
+			rule.value = canonical
 		default:
 			// Plain literal directories or files.
 			rule.mode = skipModeExact
-			rule.value = strings.TrimSuffix(raw, "/")
+			rule.value = strings.TrimSuffix(pattern, "/")
 		}
 		rules = append(rules, rule)
 	}
 	return rules
 }
 
-// matchSkip determines whether rel matches any configured skip rule.
-func matchSkip(rel string, isDir bool, rules []skipRule) (skip bool, mode skipMode, pattern string) {
-	for _, rule := range rules {
-		switch rule.mode {
-		case skipModeSubtree:
-			// Subtree skips only affect the directory itself, so children can still be processed.
-			if rel == rule.value {
-				return true, skipModeSubtree, rule.raw
-			}
-		case skipModeChildren:
-			// Children skips only apply to immediate descendants.
-			if isDir && rel == rule.value {
-				return true, skipModeChildren, rule.raw
-			}
-			if matchesChild(rel, rule.value) {
-				return true, skipModeChildren, rule.raw
-			}
-		case skipModeExact:
-			// Exact matches drop the resource entirely.
-			if rel == rule.value {
-				return true, skipModeExact, rule.raw
+// matchSkip determines whether rel is skipped; the last matching rule decides.
+func matchSkip(fullRel string, isDir bool, rules []skipRule) (skip bool, mode skipMode, pattern string) {
+	last := -1
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(fullRel, isDir) {
+			// Every matching rule counts as used, including ones a later rule overrides.
+			rules[i].markUsed()
+			if last < 0 {
+				last = i
 			}
+		}
+	}
+	if last < 0 || rules[last].negate {
+		return false, skipModeExact, ""
+	}
 
-			// Match by basename in case the pattern is relative.
-			if !strings.Contains(rule.value, "/") && path.Base(rel) == rule.value {
-				return true, skipModeExact, rule.raw
-			}
-		case skipModeGlob:
-			// Glob patterns work across the full path.
-			if matched, err := path.Match(rule.value, rel); err == nil && matched {
-				return true, skipModeGlob, rule.raw
-			}
+	rule := rules[last]
+	if rule.mode != skipModeChildren {
+		return true, rule.mode, rule.raw
+	}
 
-			// Also allow glob matches against the basename for non-path patterns.
-			if !strings.Contains(rule.value, "/") {
-				if matched, err := path.Match(rule.value, path.Base(rel)); err == nil && matched {
-					return true, skipModeGlob, rule.raw
-				}
-			}
+	// Contents of a children rule are only reached when the directory is walked; drop them.
+	if rel, _ := rule.relative(fullRel); !isDir || !matchValue(rule.value, rel) {
+		return true, skipModeExact, rule.raw
+	}
+	// A directory whose contents are skipped must still be walked when a later rule re-includes something inside it.
+	for _, later := range rules[last+1:] {
+		if later.negate && later.reincludesBelow(fullRel) {
+			return false, skipModeExact, ""
+		}
+	}
+	return true, skipModeChildren, rule.raw
+}
+
+// markUsed records that the rule matched a path.
+func (r skipRule) markUsed() {
+	if r.used != nil {
+		r.used.Store(true)
+	}
+}
+
+// relative strips the rule scope from fullRel; ok is false when fullRel lies outside the scope.
+func (r skipRule) relative(fullRel string) (string, bool) {
+	if r.scope == "" {
+		return fullRel, true
+	}
+	if !strings.HasPrefix(fullRel, r.scope+"/") {
+		return "", false
+	}
+	return fullRel[len(r.scope)+1:], true
+}
+
+// matches reports whether the rule applies to fullRel, ignoring negation.
+func (r skipRule) matches(fullRel string, isDir bool) bool {
+	rel, ok := r.relative(fullRel)
+	if !ok {
+		return false
+	}
+
+	switch r.mode {
+	case skipModeSubtree:
+		// Subtree skips only affect the directory itself, so children can still be processed.
+		return matchValue(r.value, rel)
+	case skipModeChildren:
+		// Children skips only apply to the directory and its immediate descendants.
+		if isDir && matchValue(r.value, rel) {
+			return true
+		}
+		return matchesChild(rel, r.value)
+	case skipModeExact:
+		// Exact matches drop the resource entirely, by path or by basename for relative patterns.
+		if rel == r.value {
+			return true
+		}
+		return !strings.Contains(r.value, "/") && path.Base(rel) == r.value
+	case skipModeGlob:
+		// Glob patterns work across the full path, and against the basename for non-path patterns.
+		if glob.Match(r.value, rel) {
+			return true
+		}
+		return !strings.Contains(r.value, "/") && glob.Match(r.value, path.Base(rel))
+	default:
+		return false
+	}
+}
+
+// reincludesBelow reports whether the rule could match a path inside the directory fullRel.
+func (r skipRule) reincludesBelow(fullRel string) bool {
+	rel, ok := r.relative(fullRel)
+	if !ok {
+		return false
+	}
+
+	// Name-only patterns match at any depth.
+	if (r.mode == skipModeExact || r.mode == skipModeGlob) && !strings.Contains(r.value, "/") {
+		return true
+	}
+	return matchesBelow(r.value, rel)
+}
+
+// matchesBelow reports whether the path pattern could match a path strictly inside dir.
+func matchesBelow(pattern, dir string) bool {
+	patternParts := strings.Split(pattern, "/")
+	dirParts := strings.Split(dir, "/")
+	for i, part := range dirParts {
+		if i >= len(patternParts) {
+			return false
+		}
+		if patternParts[i] == "**" {
+			return true
+		}
+		if !glob.Match(patternParts[i], part) {
+			return false
 		}
 	}
-	return false, skipModeExact, ""
+	return len(patternParts) > len(dirParts)
+}
+
+// matchValue matches a rule value against rel; literal values compare exactly.
+func matchValue(value, rel string) bool {
+	if !strings.ContainsAny(value, "*?[]\\") {
+		return rel == value
+	}
+	return glob.Match(value, rel)
 }
 
 // handleSkipDir records how a skipped directory should adjust the resource lists.
@@ -126,14 +239,14 @@ func handleSkipDir(entry os.DirEntry, mode skipMode, dirEntries []string, childD
 	}
 }
 
-// matchesChild reports whether rel is a direct child of prefix.
+// matchesChild reports whether rel is a direct child of a directory matching prefix.
 func matchesChild(rel, prefix string) bool {
+	parent := path.Dir(rel)
 	if prefix == "" {
-		return !strings.Contains(rel, "/")
+		return parent == "."
 	}
-	if !strings.HasPrefix(rel, prefix+"/") {
+	if parent == "." {
 		return false
 	}
-	rest := rel[len(prefix)+1:]
-	return rest != "" && !strings.Contains(rest, "/")
+	return matchValue(prefix, parent)
 }
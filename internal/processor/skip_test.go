@@ -1,9 +1,13 @@
 package processor
 
 import (
+	"context"
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/gi8lino/karma/internal/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,9 +42,14 @@ func TestMatchSkipModes(t *testing.T) {
 	t.Run("children rule matches directory", func(t *testing.T) {
 		t.Parallel()
 		rules := parseSkipRules([]string{"flux/config/*"})
-		ok, mode, _ := matchSkip("flux/config/child", true, rules)
+		ok, mode, _ := matchSkip("flux/config", true, rules)
 		require.True(t, ok)
 		assert.Equal(t, skipModeChildren, mode)
+
+		// Contents are dropped once the directory is walked.
+		ok, mode, _ = matchSkip("flux/config/child", true, rules)
+		require.True(t, ok)
+		assert.Equal(t, skipModeExact, mode)
 	})
 
 	t.Run("subtree with trailing slash matches", func(t *testing.T) {
@@ -59,6 +68,16 @@ func TestMatchSkipModes(t *testing.T) {
 		assert.Equal(t, skipModeGlob, mode)
 	})
 
+	t.Run("scoped rule matches relative to its directory", func(t *testing.T) {
+		t.Parallel()
+		rules := parseSkipRules([]string{"tests/*.yaml"})
+		rules[0].scope = "apps"
+		ok, _, _ := matchSkip("apps/tests/a.yaml", false, rules)
+		assert.True(t, ok)
+		ok, _, _ = matchSkip("tests/a.yaml", false, rules)
+		assert.False(t, ok)
+	})
+
 	t.Run("exact matches", func(t *testing.T) {
 		t.Parallel()
 		rules := parseSkipRules([]string{"README"})
@@ -66,6 +85,87 @@ func TestMatchSkipModes(t *testing.T) {
 		require.True(t, ok)
 		assert.Equal(t, skipModeExact, mode)
 	})
+
+	t.Run("doublestar matches any depth", func(t *testing.T) {
+		t.Parallel()
+		rules := parseSkipRules([]string{"apps/**/tests/*.yaml"})
+		for _, rel := range []string{"apps/tests/a.yaml", "apps/team/web/tests/a.yaml"} {
+			ok, mode, pattern := matchSkip(rel, false, rules)
+			require.True(t, ok, rel)
+			assert.Equal(t, skipModeGlob, mode)
+			assert.Equal(t, "apps/**/tests/*.yaml", pattern)
+		}
+		ok, _, _ := matchSkip("apps/team/tests/nested/a.yaml", false, rules)
+		assert.False(t, ok)
+	})
+
+	t.Run("doublestar inside subtree pattern", func(t *testing.T) {
+		t.Parallel()
+		rules := parseSkipRules([]string{"clusters/**/flux-system/**"})
+		ok, mode, _ := matchSkip("clusters/prod/eu/flux-system", true, rules)
+		require.True(t, ok)
+		assert.Equal(t, skipModeSubtree, mode)
+	})
+
+	t.Run("negation re-includes with last match winning", func(t *testing.T) {
+		t.Parallel()
+		rules := parseSkipRules([]string{"*.yaml", "!keep.yaml"})
+		ok, _, _ := matchSkip("apps/drop.yaml", false, rules)
+		assert.True(t, ok)
+		ok, _, _ = matchSkip("apps/keep.yaml", false, rules)
+		assert.False(t, ok)
+
+		rules = parseSkipRules([]string{"!keep.yaml", "*.yaml"})
+		ok, _, _ = matchSkip("apps/keep.yaml", false, rules)
+		assert.True(t, ok)
+	})
+
+	t.Run("negation inside skipped children", func(t *testing.T) {
+		t.Parallel()
+		rules := parseSkipRules([]string{"legacy/*", "!legacy/keep"})
+
+		// The directory must be walked so the re-included entry stays listed.
+		ok, _, _ := matchSkip("legacy", true, rules)
+		assert.False(t, ok)
+		ok, mode, _ := matchSkip("legacy/old", true, rules)
+		require.True(t, ok)
+		assert.Equal(t, skipModeExact, mode)
+		ok, _, _ = matchSkip("legacy/keep", true, rules)
+		assert.False(t, ok)
+	})
+
+	t.Run("children without negation stop the walk", func(t *testing.T) {
+		t.Parallel()
+		rules := parseSkipRules([]string{"legacy/*", "!other/keep"})
+		ok, mode, _ := matchSkip("legacy", true, rules)
+		require.True(t, ok)
+		assert.Equal(t, skipModeChildren, mode)
+	})
+}
+
+func TestUnusedSkipPatterns(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports rules that never matched a path", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(temp, "apps"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "apps", "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "apps", "test.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "apps", ConfigFileName), []byte("skip: [test.yaml, missing.yaml]\n"), 0o644))
+
+		proc := New(Options{Skip: []string{"*.yaml", "!app.yaml", "nope"}, UseConfig: true},
+			logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		for range 2 {
+			_, err := proc.Process(context.Background(), temp)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []UnusedSkipPattern{
+			{Pattern: "nope", Source: "--skip"},
+			{Pattern: "missing.yaml", Source: filepath.Join(temp, "apps", ConfigFileName)},
+		}, proc.UnusedSkipPatterns())
+	})
 }
 
 func TestHandleSkipDir(t *testing.T) {
@@ -0,0 +1,325 @@
+package processor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceLayout remembers where the managed blocks live in the original file,
+// so changes can be spliced into the text instead of re-encoding the whole document.
+type sourceLayout struct {
+	lines    []string            // Original file split on "\n".
+	seqs     map[string]*seqSpan // Spliceable block sequences by key.
+	header   []string            // Header lines added by ensureHeader.
+	headerAt int                 // Line index the header lines are inserted before.
+}
+
+// seqSpan is the line range holding the items of a block sequence, or the lines of a flow sequence.
+type seqSpan struct {
+	start, end int                 // Item lines [start, end), zero-based.
+	indent     string              // Whitespace in front of each "-".
+	chunks     map[string][]string // Original lines per entry, including the comments above it.
+
+	keyLine int    // Line of the key, replaced together with the items once the sequence becomes empty.
+	empty   string // Key line for an empty sequence; empty when the key line cannot be rewritten.
+
+	flow           bool   // The sequence is written in flow style and rewritten on a single line.
+	prefix, suffix string // Text before the opening and after the closing bracket of a flow sequence.
+}
+
+// newSourceLayout indexes the original text of mapNode; returns nil when raw is empty.
+// firstKey is the first key of the mapping before ensureHeader ran, header the nodes it added.
+func newSourceLayout(raw []byte, mapNode, firstKey *yaml.Node, header []*yaml.Node) *sourceLayout {
+	if len(raw) == 0 {
+		return nil
+	}
+	layout := &sourceLayout{
+		lines: strings.Split(string(raw), "\n"),
+		seqs:  make(map[string]*seqSpan),
+	}
+
+	if len(header) > 0 {
+		// Header keys go in front of the first key; without one there is nothing to anchor to.
+		if firstKey == nil || firstKey.Column != 1 {
+			return nil
+		}
+		layout.headerAt = firstKey.Line - 1
+		for i := 0; i+1 < len(header); i += 2 {
+			layout.header = append(layout.header, header[i].Value+": "+header[i+1].Value)
+		}
+	}
+
+	for _, key := range []string{"resources", "components"} {
+		if span := locateSeq(mapNode, key, layout.lines); span != nil {
+			layout.seqs[key] = span
+		}
+	}
+	return layout
+}
+
+// locateSeq returns the span of the sequence stored under key, or nil when it cannot be spliced.
+// Block sequences of single-line scalars and flow sequences of scalars without comments qualify.
+func locateSeq(mapNode *yaml.Node, key string, lines []string) *seqSpan {
+	var keyNode, seq *yaml.Node
+	for i := 0; i+1 < len(mapNode.Content); i += 2 {
+		if mapNode.Content[i].Value == key {
+			keyNode, seq = mapNode.Content[i], mapNode.Content[i+1]
+			break
+		}
+	}
+	if seq == nil || seq.Kind != yaml.SequenceNode || keyNode.Line < 1 || keyNode.Line > len(lines) {
+		return nil
+	}
+	if seq.Style&yaml.FlowStyle != 0 {
+		return locateFlowSeq(seq, lines)
+	}
+	if len(seq.Content) == 0 {
+		return nil
+	}
+
+	span := &seqSpan{start: keyNode.Line, indent: "", chunks: make(map[string][]string)}
+	span.keyLine = keyNode.Line - 1
+	span.empty = emptyKeyLine(lines[span.keyLine], keyNode)
+	prev := span.start
+	for i, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode || item.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return nil
+		}
+		line := item.Line - 1
+		if line < prev || line >= len(lines) {
+			return nil
+		}
+
+		// Every item must sit on its own "- value" line at the same indentation.
+		text := lines[line]
+		trimmed := strings.TrimLeft(text, " ")
+		if !strings.HasPrefix(trimmed, "-") {
+			return nil
+		}
+		indent := text[:len(text)-len(trimmed)]
+		if i == 0 {
+			span.indent = indent
+		} else if indent != span.indent {
+			return nil
+		}
+
+		// Lines between items may only hold comments or blanks; they travel with the next item.
+		for _, between := range lines[prev:line] {
+			if !isBlankOrComment(between) {
+				return nil
+			}
+		}
+		if _, ok := span.chunks[item.Value]; !ok {
+			span.chunks[item.Value] = slices.Clone(lines[prev : line+1])
+		}
+		prev = line + 1
+	}
+	span.end = prev
+
+	// A deeper indented line right after the last item continues a multi-line scalar.
+	if span.end < len(lines) {
+		next := lines[span.end]
+		if !isBlankOrComment(next) && len(next)-len(strings.TrimLeft(next, " ")) > len(span.indent) {
+			return nil
+		}
+	}
+	return span
+}
+
+// emptyKeyLine rewrites the line holding keyNode to carry an empty flow sequence, keeping a trailing comment.
+// It returns "" when anything else follows the key.
+func emptyKeyLine(line string, keyNode *yaml.Node) string {
+	end := keyNode.Column - 1 + len(keyNode.Value) + 1
+	if keyNode.Style != 0 || end > len(line) || line[keyNode.Column-1:end] != keyNode.Value+":" {
+		return ""
+	}
+	rest := line[end:]
+	if trimmed := strings.TrimSpace(rest); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+		return ""
+	}
+	if strings.TrimSpace(rest) == "" {
+		rest = ""
+	}
+	return line[:end] + " []" + rest
+}
+
+// locateFlowSeq returns the span of a flow sequence of plain or quoted scalars, or nil when it holds anything else.
+func locateFlowSeq(seq *yaml.Node, lines []string) *seqSpan {
+	for _, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode || item.Anchor != "" || item.Tag != "!!str" && item.Tag != "" {
+			return nil
+		}
+	}
+	line, col := seq.Line-1, seq.Column-1
+	if line < 0 || line >= len(lines) || col >= len(lines[line]) || lines[line][col] != '[' {
+		return nil
+	}
+	endLine, endCol, ok := flowEnd(lines, line, col)
+	if !ok {
+		return nil
+	}
+	return &seqSpan{
+		start:  line,
+		end:    endLine + 1,
+		flow:   true,
+		prefix: lines[line][:col],
+		suffix: lines[endLine][endCol:],
+	}
+}
+
+// flowEnd returns the line and byte offset just past the flow collection opening at lines[line][col].
+// Collections holding comments or quoted scalars spanning lines are rejected, since a rewrite would lose them.
+func flowEnd(lines []string, line, col int) (int, int, bool) {
+	depth := 0
+	for l := line; l < len(lines); l++ {
+		text := lines[l]
+		i := 0
+		if l == line {
+			i = col
+		}
+		for ; i < len(text); i++ {
+			switch text[i] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					return l, i + 1, true
+				}
+			case '"', '\'':
+				end := quotedEnd(text, i)
+				if end < 0 {
+					return 0, 0, false
+				}
+				i = end
+			case '#':
+				if i == 0 || text[i-1] == ' ' || text[i-1] == '\t' {
+					return 0, 0, false
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// quotedEnd returns the offset of the quote closing the scalar opened at text[start], or -1 when it ends on a later line.
+func quotedEnd(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// isBlankOrComment reports whether line holds nothing but whitespace or a comment.
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// splice returns the original text with the sequences in changed replaced.
+// The second return value is false when the layout cannot express the change.
+func (l *sourceLayout) splice(changed map[string][]string) ([]byte, bool) {
+	if l == nil {
+		return nil, false
+	}
+
+	type replacement struct {
+		start, end int
+		lines      []string
+	}
+	replacements := make([]replacement, 0, len(changed))
+	for key, values := range changed {
+		span := l.seqs[key]
+		if span == nil {
+			return nil, false
+		}
+		start, lines, err := span.render(values)
+		if err != nil {
+			return nil, false
+		}
+		replacements = append(replacements, replacement{start: start, end: span.end, lines: lines})
+	}
+
+	// Replace bottom-up so earlier line indexes stay valid.
+	slices.SortFunc(replacements, func(a, b replacement) int { return b.start - a.start })
+	lines := slices.Clone(l.lines)
+	for _, r := range replacements {
+		lines = slices.Replace(lines, r.start, r.end, r.lines...)
+	}
+	if len(l.header) > 0 {
+		lines = slices.Insert(lines, l.headerAt, l.header...)
+	}
+	return []byte(strings.Join(lines, "\n")), true
+}
+
+// render builds the lines replacing [start, s.end) for values, reusing the original lines of known entries.
+// An emptied block sequence collapses into its key line; a flow sequence stays on the line it started on.
+func (s *seqSpan) render(values []string) (start int, lines []string, err error) {
+	if s.flow {
+		items := make([]string, 0, len(values))
+		for _, value := range values {
+			text, err := renderFlowScalar(value)
+			if err != nil {
+				return 0, nil, err
+			}
+			items = append(items, text)
+		}
+		return s.start, []string{s.prefix + "[" + strings.Join(items, ", ") + "]" + s.suffix}, nil
+	}
+	if len(values) == 0 {
+		if s.empty == "" {
+			return 0, nil, fmt.Errorf("key line cannot hold an empty sequence")
+		}
+		return s.keyLine, []string{s.empty}, nil
+	}
+
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if chunk, ok := s.chunks[value]; ok {
+			out = append(out, chunk...)
+			continue
+		}
+		text, err := renderScalar(value)
+		if err != nil {
+			return 0, nil, err
+		}
+		out = append(out, s.indent+"- "+text)
+	}
+	return s.start, out, nil
+}
+
+// renderFlowScalar quotes value for a flow sequence, where indicators such as "," or "]" end a plain scalar.
+func renderFlowScalar(value string) (string, error) {
+	text, err := renderScalar(value)
+	if err != nil || !strings.ContainsAny(text, ",[]{}") || strings.HasPrefix(text, "'") || strings.HasPrefix(text, "\"") {
+		return text, err
+	}
+	data, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: value})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// renderScalar quotes value the way the encoder would for a single-line sequence entry.
+func renderScalar(value string) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if strings.Contains(text, "\n") {
+		return "", fmt.Errorf("value %q does not fit on one line", value)
+	}
+	return text, nil
+}
@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorSpliceKustomization(t *testing.T) {
+	t.Parallel()
+
+	update := func(t *testing.T, content string, entries listing) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+		upd, err := proc.updateKustomization(context.Background(), filepath.Dir(path), path, true, entries)
+		require.NoError(t, err)
+		require.True(t, upd.changed)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("keeps unrelated formatting", func(t *testing.T) {
+		t.Parallel()
+		content := "apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+			"kind: Kustomization\n" +
+			"labels:\n" +
+			"- pairs: {app: demo}\n" +
+			"\n" +
+			"resources:\n" +
+			"    - old.yaml\n" +
+			"    - 'app.yaml'\n" +
+			"\n" +
+			"images:\n" +
+			"- name: \"nginx\"\n" +
+			"  newTag: '1.25'\n"
+		got := update(t, content, listing{files: []string{"app.yaml", "new.yaml"}})
+		want := "apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+			"kind: Kustomization\n" +
+			"labels:\n" +
+			"- pairs: {app: demo}\n" +
+			"\n" +
+			"resources:\n" +
+			"    - 'app.yaml'\n" +
+			"    - new.yaml\n" +
+			"\n" +
+			"images:\n" +
+			"- name: \"nginx\"\n" +
+			"  newTag: '1.25'\n"
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("moves comments with their entries", func(t *testing.T) {
+		t.Parallel()
+		content := "kind: Kustomization\n" +
+			"apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+			"resources:\n" +
+			"  # the app\n" +
+			"  - b.yaml # trailing\n" +
+			"  - a.yaml\n" +
+			"  # footer stays\n"
+		got := update(t, content, listing{files: []string{"a.yaml", "b.yaml"}})
+		want := "kind: Kustomization\n" +
+			"apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+			"resources:\n" +
+			"  - a.yaml\n" +
+			"  # the app\n" +
+			"  - b.yaml # trailing\n" +
+			"  # footer stays\n"
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("inserts missing header", func(t *testing.T) {
+		t.Parallel()
+		content := "---\n# my overlay\nresources:\n  - old.yaml\n"
+		got := update(t, content, listing{files: []string{"app.yaml"}})
+		want := "---\n# my overlay\n" +
+			"apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+			"kind: Kustomization\n" +
+			"resources:\n" +
+			"  - app.yaml\n"
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("re-encodes when the block is missing", func(t *testing.T) {
+		t.Parallel()
+		content := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n"
+		got := update(t, content, listing{files: []string{"app.yaml"}})
+		assert.Equal(t, "---\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - app.yaml\n", got)
+	})
+
+	// namespace and images are formatted in ways a re-encode would change.
+	const (
+		namespace = "namespace:   foo   # keep spacing\n"
+		images    = "images:\n- name: \"nginx\"\n  newTag:    '1.25'\n"
+		header    = "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n" + namespace
+	)
+
+	t.Run("rewrites flow sequences in place", func(t *testing.T) {
+		t.Parallel()
+		content := header + "resources: [old.yaml,\n  'app.yaml']  # generated\n" + images
+		got := update(t, content, listing{files: []string{"app.yaml", "a,b.yaml"}})
+		assert.Equal(t, header+"resources: [\"a,b.yaml\", app.yaml]  # generated\n"+images, got)
+	})
+
+	t.Run("empties block sequences in place", func(t *testing.T) {
+		t.Parallel()
+		content := header + "resources: # managed by karma\n  # old entry\n  - old.yaml\n" + images
+		got := update(t, content, listing{})
+		assert.Equal(t, header+"resources: [] # managed by karma\n"+images, got)
+	})
+
+	t.Run("empties flow sequences in place", func(t *testing.T) {
+		t.Parallel()
+		content := header + "resources: [old.yaml]\n" + images
+		got := update(t, content, listing{})
+		assert.Equal(t, header+"resources: []\n"+images, got)
+	})
+
+	t.Run("re-encodes flow sequences holding comments", func(t *testing.T) {
+		t.Parallel()
+		content := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: [\n  old.yaml, # old\n]\n"
+		got := update(t, content, listing{files: []string{"app.yaml"}})
+		assert.True(t, strings.HasPrefix(got, "---\n"), got)
+		assert.Contains(t, got, "app.yaml")
+	})
+}
+
+func TestLocateSeq(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rejects multi-line scalars", func(t *testing.T) {
+		t.Parallel()
+		layout := newSourceLayoutFor(t, []byte("resources:\n  - a\n    b\n"))
+		assert.NotContains(t, layout.seqs, "resources")
+	})
+
+	t.Run("records compact sequences", func(t *testing.T) {
+		t.Parallel()
+		layout := newSourceLayoutFor(t, []byte("resources:\n- a\n- b\nimages: []\n"))
+		require.Contains(t, layout.seqs, "resources")
+		span := layout.seqs["resources"]
+		assert.Equal(t, 1, span.start)
+		assert.Equal(t, 3, span.end)
+		assert.Equal(t, "", span.indent)
+	})
+}
+
+// newSourceLayoutFor loads raw as a kustomization and returns its source layout.
+func newSourceLayoutFor(t *testing.T, raw []byte) *sourceLayout {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kustomization.yaml")
+	require.NoError(t, os.WriteFile(path, raw, 0o644))
+	proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+	doc, err := proc.loadKustomization(path, true)
+	require.NoError(t, err)
+	require.NotNil(t, doc.layout)
+	return doc.layout
+}
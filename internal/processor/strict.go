@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic points at content that strict mode refuses to coerce or drop.
+type Diagnostic struct {
+	Path   string // File holding the content.
+	Line   int    // 1-based line of the offending node.
+	Column int    // 1-based column of the offending node.
+	Msg    string // What was unexpected.
+}
+
+// Error renders the diagnostic as "<path>:<line>:<column>: <msg>".
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.Path, d.Line, d.Column, d.Msg)
+}
+
+// checkStructure reports every node of the parsed kustomization at path that an update would coerce or drop:
+// a top level that is not a mapping, managed blocks that are not sequences, and entries that are not scalars.
+func checkStructure(path string, root *yaml.Node) []error {
+	if len(root.Content) == 0 {
+		return nil
+	}
+	diagnose := func(node *yaml.Node, format string, args ...any) error {
+		return &Diagnostic{Path: path, Line: node.Line, Column: node.Column, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	if err := checkTopLevel(path, root); err != nil {
+		return []error{err}
+	}
+	top := root.Content[0]
+	if top.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var diags []error
+	for _, key := range []string{"resources", "components"} {
+		seq := mappingValue(top, key)
+		if seq == nil || isNull(seq) {
+			continue
+		}
+		if seq.Kind != yaml.SequenceNode {
+			diags = append(diags, diagnose(seq, "expected a sequence for %s, found %s", key, nodeKind(seq)))
+			continue
+		}
+		for _, entry := range seq.Content {
+			if entry.Kind != yaml.ScalarNode {
+				diags = append(diags, diagnose(entry, "expected a scalar entry in %s, found %s", key, nodeKind(entry)))
+			}
+		}
+	}
+	return diags
+}
+
+// checkTopLevel refuses a parsed kustomization at path whose top level is neither a mapping nor null,
+// since replacing a scalar or sequence would lose it even outside strict mode.
+func checkTopLevel(path string, root *yaml.Node) error {
+	if len(root.Content) == 0 {
+		return nil
+	}
+	top := root.Content[0]
+	if top.Kind == yaml.MappingNode || isNull(top) {
+		return nil
+	}
+	return &Diagnostic{
+		Path:   path,
+		Line:   top.Line,
+		Column: top.Column,
+		Msg:    fmt.Sprintf("expected a mapping at the top level, found %s", nodeKind(top)),
+	}
+}
+
+// isNull reports whether node is an explicit or implicit null, which is safe to replace.
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// nodeKind describes the kind of node for diagnostics.
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a sequence"
+	case yaml.ScalarNode:
+		return fmt.Sprintf("the scalar %q", node.Value)
+	case yaml.AliasNode:
+		return "an alias"
+	default:
+		return "a document"
+	}
+}
@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gi8lino/karma/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCheckStructure(t *testing.T) {
+	t.Parallel()
+
+	check := func(t *testing.T, content string) []string {
+		t.Helper()
+		var root yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(content), &root))
+		var got []string
+		for _, err := range checkStructure("k.yaml", &root) {
+			got = append(got, err.Error())
+		}
+		return got
+	}
+
+	t.Run("accepts well-formed kustomizations", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, check(t, "resources:\n  - app.yaml\ncomponents: [comp]\n"))
+	})
+
+	t.Run("accepts empty and null blocks", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, check(t, ""))
+		assert.Empty(t, check(t, "~\n"))
+		assert.Empty(t, check(t, "resources:\ncomponents: null\n"))
+	})
+
+	t.Run("rejects a non-mapping document", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, []string{"k.yaml:1:1: expected a mapping at the top level, found a sequence"}, check(t, "- app.yaml\n"))
+	})
+
+	t.Run("rejects non-sequence blocks", func(t *testing.T) {
+		t.Parallel()
+		got := check(t, "resources: app.yaml\ncomponents:\n  comp: true\n")
+		assert.Equal(t, []string{
+			`k.yaml:1:12: expected a sequence for resources, found the scalar "app.yaml"`,
+			"k.yaml:3:3: expected a sequence for components, found a mapping",
+		}, got)
+	})
+
+	t.Run("rejects non-scalar entries", func(t *testing.T) {
+		t.Parallel()
+		got := check(t, "resources:\n  - app.yaml\n  - path: nested.yaml\n  - [a, b]\n")
+		assert.Equal(t, []string{
+			"k.yaml:3:5: expected a scalar entry in resources, found a mapping",
+			"k.yaml:4:5: expected a scalar entry in resources, found a sequence",
+		}, got)
+	})
+}
+
+func TestProcessorStrict(t *testing.T) {
+	t.Parallel()
+
+	t.Run("refuses to touch malformed kustomizations", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		original := "resources:\n  - path: nested.yaml\n"
+		require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		proc := New(Options{Strict: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.Error(t, err)
+		var diag *Diagnostic
+		require.ErrorAs(t, err, &diag)
+		assert.Equal(t, Diagnostic{Path: path, Line: 2, Column: 5, Msg: "expected a scalar entry in resources, found a mapping"}, *diag)
+		assert.EqualError(t, err, path+":2:5: expected a scalar entry in resources, found a mapping")
+		var pe *PathError
+		require.ErrorAs(t, err, &pe)
+		assert.Equal(t, OpParse, pe.Op)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, original, string(data))
+	})
+
+	t.Run("replaces a null document with a plain mapping", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("~\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		proc := New(Options{Strict: true}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "---\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - app.yaml\n", string(data))
+	})
+
+	t.Run("coerces without strict mode", func(t *testing.T) {
+		t.Parallel()
+		temp := t.TempDir()
+		path := filepath.Join(temp, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("resources: app.yaml\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+		proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+		_, err := proc.Process(context.Background(), temp)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "- app.yaml")
+	})
+	t.Run("refuses scalar and sequence documents without strict mode", func(t *testing.T) {
+		t.Parallel()
+		for content, msg := range map[string]string{
+			"app.yaml\n":   `expected a mapping at the top level, found the scalar "app.yaml"`,
+			"- app.yaml\n": "expected a mapping at the top level, found a sequence",
+		} {
+			temp := t.TempDir()
+			path := filepath.Join(temp, "kustomization.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(temp, "app.yaml"), []byte("kind: ConfigMap\n"), 0o644))
+			proc := New(Options{}, logging.New(io.Discard, io.Discard, logging.LevelInfo))
+
+			_, err := proc.Process(context.Background(), temp)
+			require.Error(t, err)
+			var diag *Diagnostic
+			require.ErrorAs(t, err, &diag)
+			assert.Equal(t, Diagnostic{Path: path, Line: 1, Column: 1, Msg: msg}, *diag)
+			var pe *PathError
+			require.ErrorAs(t, err, &pe)
+			assert.Equal(t, OpParse, pe.Op)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(data))
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package processor
 
-import "strings"
+import (
+	"path"
+	"strings"
+)
 
 // isKustomization reports whether name is a recognized kustomization file name.
 func isKustomization(name string) bool {
@@ -13,7 +16,12 @@ func isYAML(name string) bool {
 	return strings.HasSuffix(lowered, ".yaml") || strings.HasSuffix(lowered, ".yml")
 }
 
-// isRemoteResource returns true for HTTP(S) resource references.
-func isRemoteResource(entry string) bool {
-	return strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://")
+// isExternalReference returns true for local paths that point outside the directory,
+// including ones that only leave it after cleaning, such as "./../x" or "foo/../../x".
+func isExternalReference(entry string) bool {
+	if path.IsAbs(entry) {
+		return true
+	}
+	cleaned := path.Clean(entry)
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../")
 }
@@ -61,4 +61,66 @@ func TestIsRemoteResource(t *testing.T) {
 		t.Parallel()
 		assert.False(t, isRemoteResource("file://local"))
 	})
+
+	t.Run("remote forms", func(t *testing.T) {
+		t.Parallel()
+		for _, entry := range []string{
+			"github.com/org/repo//deploy?ref=v1.2",
+			"github.com/org/repo/deploy",
+			"gitlab.com/group/project//base",
+			"git@github.com:org/repo.git//x",
+			"git::https://example.com/org/repo.git//base?ref=main",
+			"ssh://git@example.com/org/repo.git",
+			"git://example.com/org/repo",
+			"oci://ghcr.io/org/manifests:1.0.0",
+			"example.com:8443/org/repo//overlays/prod",
+			"../charts?ref=main",
+			"HTTPS://EXAMPLE.COM/x.yaml",
+		} {
+			assert.True(t, isRemoteResource(entry), entry)
+		}
+	})
+
+	t.Run("local forms", func(t *testing.T) {
+		t.Parallel()
+		for _, entry := range []string{
+			"",
+			"app.yaml",
+			"./apps/",
+			"../base",
+			"config.d/app",
+			"v1.2/app.yaml",
+			"dir/?query",
+		} {
+			assert.False(t, isRemoteResource(entry), entry)
+		}
+	})
+}
+
+func TestIsExternalReference(t *testing.T) {
+	t.Parallel()
+
+	t.Run("parent directory", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, isExternalReference("../base"))
+	})
+
+	t.Run("absolute path", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, isExternalReference("/srv/base"))
+	})
+
+	t.Run("paths leaving the directory after cleaning", func(t *testing.T) {
+		t.Parallel()
+		for _, entry := range []string{"..", "../", "./../x", "foo/../../x"} {
+			assert.True(t, isExternalReference(entry), entry)
+		}
+	})
+
+	t.Run("local entry", func(t *testing.T) {
+		t.Parallel()
+		for _, entry := range []string{"./app", "app/../other", "..app", "app/.."} {
+			assert.False(t, isExternalReference(entry), entry)
+		}
+	})
 }
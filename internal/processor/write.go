@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// newFileMode is the mode, before the umask, of kustomizations that did not exist before.
+const newFileMode fs.FileMode = 0o666
+
+// writeKustomization atomically replaces path with data.
+// The content goes to a temp file in the same directory which is synced and renamed over the target,
+// so an interrupted run never leaves a truncated file. Symlinks are followed and the existing
+// mode and, where permitted, ownership are kept.
+func writeKustomization(path string, data []byte) error {
+	target, err := resolveTarget(path)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", path, err)
+	}
+
+	info, err := os.Stat(target)
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist):
+		info = nil
+	default:
+		return fmt.Errorf("stat %s: %w", target, err)
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := createTemp(dir, "."+filepath.Base(target)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("create temp file for %s: %w", target, err)
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()        // nolint:errcheck
+			os.Remove(tmpName) // nolint:errcheck
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("write content: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", tmpName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", tmpName, err)
+	}
+	// New files keep the umask-derived mode of the temp file, like any other file the user creates.
+	if info != nil {
+		if err := os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("chmod %s: %w", tmpName, err)
+		}
+		if err := preserveOwner(tmpName, info); err != nil {
+			return fmt.Errorf("chown %s: %w", tmpName, err)
+		}
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		return fmt.Errorf("rename %s: %w", target, err)
+	}
+	committed = true
+
+	// Persist the rename itself; the content is already on disk.
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// createTemp creates a new file in dir whose name starts with prefix, like os.CreateTemp,
+// but with newFileMode minus the process umask instead of 0o600.
+func createTemp(dir, prefix string) (*os.File, error) {
+	for range 10000 {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, newFileMode)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*"), Err: fs.ErrExist}
+}
+
+// resolveTarget returns the file a write to path should replace.
+// Symlinks, including dangling ones, resolve to their target; missing files resolve to path.
+func resolveTarget(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	link, err := os.Readlink(path)
+	if err != nil {
+		// Not a symlink: the file is simply missing and gets created in place.
+		return path, nil
+	}
+	if !filepath.IsAbs(link) {
+		link = filepath.Join(filepath.Dir(path), link)
+	}
+	return link, nil
+}
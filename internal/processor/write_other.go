@@ -0,0 +1,11 @@
+//go:build !unix
+
+package processor
+
+import "io/fs"
+
+// preserveOwner is a no-op where file ownership is not expressed as uid and gid.
+func preserveOwner(string, fs.FileInfo) error { return nil }
+
+// syncDir is a no-op where directories cannot be opened for syncing.
+func syncDir(string) error { return nil }
@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteKustomization(t *testing.T) {
+	t.Parallel()
+
+	t.Run("creates new file", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "kustomization.yaml")
+		require.NoError(t, writeKustomization(path, []byte("resources: []\n")))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "resources: []\n", string(data))
+
+		// New files get the same umask-derived mode as any other newly created file.
+		ref := filepath.Join(dir, "ref.yaml")
+		require.NoError(t, os.WriteFile(ref, nil, 0o666))
+		want, err := os.Stat(ref)
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want.Mode().Perm(), info.Mode().Perm())
+	})
+
+	t.Run("preserves mode and leaves no temp files", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+		require.NoError(t, os.Chmod(path, 0o640))
+
+		require.NoError(t, writeKustomization(path, []byte("new\n")))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "kustomization.yaml", entries[0].Name())
+	})
+
+	t.Run("writes through symlinks", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		target := filepath.Join(dir, "real.yaml")
+		link := filepath.Join(dir, "kustomization.yaml")
+		require.NoError(t, os.WriteFile(target, []byte("old\n"), 0o644))
+		require.NoError(t, os.Symlink("real.yaml", link))
+
+		require.NoError(t, writeKustomization(link, []byte("new\n")))
+
+		info, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.Equal(t, os.ModeSymlink, info.Mode()&os.ModeSymlink)
+		data, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(data))
+	})
+
+	t.Run("creates target of dangling symlink", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		link := filepath.Join(dir, "kustomization.yaml")
+		require.NoError(t, os.Symlink("real.yaml", link))
+
+		require.NoError(t, writeKustomization(link, []byte("new\n")))
+
+		data, err := os.ReadFile(filepath.Join(dir, "real.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(data))
+	})
+
+	t.Run("fails when directory is missing", func(t *testing.T) {
+		t.Parallel()
+		err := writeKustomization(filepath.Join(t.TempDir(), "missing", "kustomization.yaml"), []byte("x\n"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package processor
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// preserveOwner copies the uid and gid of info to path; lacking the privilege is not an error.
+func preserveOwner(path string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Lchown(path, int(stat.Uid), int(stat.Gid)); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return nil
+}
+
+// syncDir flushes directory metadata such as a completed rename.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close() // nolint:errcheck
+	return d.Sync()
+}